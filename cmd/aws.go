@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KineticCommerce/kci/replay"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+var loadedAWSConfig *aws.Config
+
+// awsConfig returns the AWS config for the environment selected with
+// --environment. It is loaded once and shared by all managers.
+//
+// When replaying, no profile or credentials are needed: the region comes from
+// the recording and requests are signed with dummy credentials.
+func awsConfig() aws.Config {
+	if loadedAWSConfig != nil {
+		return *loadedAWSConfig
+	}
+
+	if replayDir != "" {
+		cfg := replayAWSConfig()
+		loadedAWSConfig = &cfg
+		return cfg
+	}
+
+	env, err := settings.Lookup(environmentName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := env.LoadAWSConfig(context.Background(), config.WithHTTPClient(httpClient))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if recordDir != "" {
+		err = replay.SaveMeta(recordDir, replay.Meta{
+			Environment: env.Name,
+			Region:      cfg.Region,
+			RecordedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	loadedAWSConfig = &cfg
+
+	return cfg
+}
+
+func replayAWSConfig() aws.Config {
+	meta, err := replay.LoadMeta(replayDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if meta.Environment != environmentName {
+		log.Printf("replaying a recording of %s as %s", meta.Environment, environmentName)
+	}
+
+	cfg := aws.NewConfig()
+	cfg.Region = meta.Region
+	cfg.Credentials = credentials.NewStaticCredentialsProvider("replay", "replay", "")
+	cfg.HTTPClient = httpClient
+	// a missing recording will not appear on retry
+	cfg.Retryer = func() aws.Retryer { return aws.NopRetryer{} }
+
+	return *cfg
+}
+
+// awsCLIEnv returns the process environment for running the aws CLI against
+// the selected environment. The credentials of awsConfig are passed on, so an
+// assumed role applies to the CLI too.
+func awsCLIEnv() ([]string, error) {
+	cfg := awsConfig()
+
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve AWS credentials: %w", err)
+	}
+
+	var env []string
+	for _, v := range os.Environ() {
+		// a profile would take precedence over the credentials
+		if !strings.HasPrefix(v, "AWS_PROFILE=") && !strings.HasPrefix(v, "AWS_DEFAULT_PROFILE=") {
+			env = append(env, v)
+		}
+	}
+
+	return append(env,
+		"AWS_ACCESS_KEY_ID="+creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+		"AWS_SESSION_TOKEN="+creds.SessionToken,
+		"AWS_REGION="+cfg.Region,
+		"AWS_DEFAULT_REGION="+cfg.Region,
+	), nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "display the configured environments",
+	Long: `Display the configured environments. The configuration is read from
+$KCI_CONFIG or ~/.config/kci/config.yaml, falling back to the builtin
+environments when neither exists. Use --output yaml to see the full file
+including per-command defaults.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		report := output.NewReport("Name", "Profile", "Region", "Account", "Status URL", "Jump Host", "Jump User", "Scan User", "Protected")
+		report.Data = settings
+
+		for _, env := range settings.Environments {
+			report.Append([]string{
+				env.Name,
+				env.Profile,
+				env.Region,
+				env.AccountID,
+				env.StatusURL,
+				env.JumpHost,
+				env.JumpUser,
+				env.ScanUser,
+				strconv.FormatBool(env.Protected),
+			})
+		}
+
+		render(report)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+}
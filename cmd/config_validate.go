@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/KineticCommerce/kci/environment"
+	"github.com/spf13/cobra"
+)
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check the configuration file for errors",
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := environment.ConfigPath()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cfg, err := environment.LoadConfig(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = validateDefaults(cmd.Root(), cfg)
+		if err != nil {
+			log.Fatalf("invalid config %s: %v", path, err)
+		}
+
+		if cfg.Path == "" {
+			fmt.Printf("%s does not exist, using builtin configuration\n", path)
+			return
+		}
+
+		fmt.Printf("%s is valid\n", cfg.Path)
+	},
+}
+
+// validateDefaults checks that every per-command default names an existing
+// command and flag.
+func validateDefaults(root *cobra.Command, cfg *environment.Config) error {
+	for key, flags := range cfg.Defaults {
+		if key == "global" {
+			for name := range flags {
+				if root.PersistentFlags().Lookup(name) == nil && !anyCommandHasFlag(root, name) {
+					return fmt.Errorf("global default %q is not a flag of any command", name)
+				}
+			}
+			continue
+		}
+
+		cmd, rest, err := root.Find(strings.Fields(key))
+		if err != nil || len(rest) > 0 || cmd == root {
+			return fmt.Errorf("defaults for unknown command %q", key)
+		}
+
+		for name := range flags {
+			if cmd.Flags().Lookup(name) == nil && cmd.InheritedFlags().Lookup(name) == nil {
+				return fmt.Errorf("default %q is not a flag of %q", name, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func anyCommandHasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil {
+		return true
+	}
+
+	for _, child := range cmd.Commands() {
+		if anyCommandHasFlag(child, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
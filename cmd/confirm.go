@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// confirmProtected asks for the environment name to be typed before a change
+// to a protected environment, unless yes is set. It fails when there is no
+// terminal to ask on.
+func confirmProtected(action string, yes bool) error {
+	env, err := settings.Lookup(environmentName)
+	if err != nil {
+		return err
+	}
+	if !env.Protected || yes {
+		return nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("%s is protected and there is no terminal to confirm on, use --yes", env.Name)
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s in protected environment %s. Type the environment name to continue: ", action, env.Name)
+
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("unable to read confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != env.Name {
+		return fmt.Errorf("not confirmed, nothing was changed")
+	}
+
+	return nil
+}
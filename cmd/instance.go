@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 
+	"github.com/KineticCommerce/kci/ec2_instance"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +21,97 @@ var instanceCmd = &cobra.Command{
 	},
 }
 
+// addInstanceFlag adds --instance (-i), the flag every command acting on a
+// single instance takes its Name tag or ID from.
+func addInstanceFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringP("instance", "i", "", usage)
+}
+
+// addInstanceIDFlag adds --instance-id, the former name of --instance, as a
+// deprecated alias.
+func addInstanceIDFlag(cmd *cobra.Command) {
+	cmd.Flags().String("instance-id", "", "the instance name or ID")
+	cmd.Flags().MarkDeprecated("instance-id", "use --instance or the instance argument instead")
+}
+
+// instanceArg returns the instance given as the argument or with --instance,
+// if any.
+func instanceArg(cmd *cobra.Command, args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+
+	nameOrID, _ := cmd.Flags().GetString("instance")
+	if nameOrID == "" && cmd.Flags().Lookup("instance-id") != nil {
+		nameOrID, _ = cmd.Flags().GetString("instance-id")
+	}
+
+	return nameOrID
+}
+
+// selectInstance returns the running instance with the given Name tag or ID.
+// Without one, the user picks from the running instances on a terminal,
+// limited to SSM managed instances when ssmOnly is set.
+func selectInstance(nameOrID string, ssmOnly bool) (ec2_instance.EC2Instance, error) {
+	manager, err := ec2_instance.NewManager(awsConfig())
+	if err != nil {
+		return ec2_instance.EC2Instance{}, err
+	}
+
+	if nameOrID != "" {
+		return manager.FindInstance(nameOrID)
+	}
+
+	if !canPick() {
+		return ec2_instance.EC2Instance{}, errors.New("no instance given, pass its name or ID")
+	}
+
+	err = manager.FetchInstances("")
+	if err != nil {
+		return ec2_instance.EC2Instance{}, err
+	}
+
+	manager.Filter(ec2_instance.IsRunningFilter)
+
+	if ssmOnly {
+		err = manager.FetchSSMDetails()
+		if err != nil {
+			return ec2_instance.EC2Instance{}, err
+		}
+
+		manager.Filter(func(instance ec2_instance.EC2Instance) bool {
+			return instance.IsSSM
+		})
+	}
+
+	if len(manager.Instances) == 0 {
+		return ec2_instance.EC2Instance{}, ec2_instance.ErrInstanceNotFound
+	}
+
+	sort.Slice(manager.Instances, func(i, j int) bool {
+		return manager.Instances[i].Name < manager.Instances[j].Name
+	})
+
+	width := 0
+	for _, instance := range manager.Instances {
+		if len(instance.Name) > width {
+			width = len(instance.Name)
+		}
+	}
+
+	items := make([]string, len(manager.Instances))
+	for i, instance := range manager.Instances {
+		items[i] = fmt.Sprintf("%-*s  %-19s  %s", width, instance.Name, instance.ID, instance.PrivateIP)
+	}
+
+	i, err := pick("instance> ", items)
+	if err != nil {
+		return ec2_instance.EC2Instance{}, err
+	}
+
+	return manager.Instances[i], nil
+}
+
 func init() {
 	rootCmd.AddCommand(instanceCmd)
 }
@@ -2,12 +2,11 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"sort"
 	"strconv"
 
 	"github.com/KineticCommerce/kci/ec2_instance"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,13 +15,16 @@ var instanceAgingCmd = &cobra.Command{
 	Short: "list KCS instances that are a little long in the tooth",
 	Run: func(cmd *cobra.Command, args []string) {
 		filter, _ := cmd.Flags().GetString("filter")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
 		includeAll, _ := cmd.Flags().GetBool("all")
 
 		// Get with main filters
-		manager, err := ec2_instance.NewManager()
+		manager, err := ec2_instance.NewManager(awsConfig())
 		if err != nil {
 			log.Fatal(err)
 		}
+		manager.PageSize = pageSize
+
 		err = manager.FetchInstances(filter)
 		if err != nil {
 			log.Fatal(err)
@@ -52,11 +54,11 @@ var instanceAgingCmd = &cobra.Command{
 			return iAge < jAge
 		})
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Name", "ID", "AMI ID", "Instance Age", "AMI Age", "Status"})
+		report := output.NewReport("Name", "ID", "AMI ID", "Instance Age", "AMI Age", "Status")
+		report.Data = manager.Instances
 
 		for _, instance := range manager.Instances {
-			table.Append([]string{
+			report.Append([]string{
 				instance.Name,
 				instance.ID,
 				instance.AMI_ID,
@@ -66,12 +68,13 @@ var instanceAgingCmd = &cobra.Command{
 			})
 		}
 
-		table.Render()
+		render(report)
 	},
 }
 
 func init() {
 	instanceCmd.AddCommand(instanceAgingCmd)
 	instanceAgingCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	instanceAgingCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
 	instanceAgingCmd.Flags().BoolP("all", "a", false, "Include all statuses in the list")
 }
@@ -2,11 +2,10 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"sort"
 
 	"github.com/KineticCommerce/kci/ec2_instance"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
@@ -15,12 +14,15 @@ var instanceListCmd = &cobra.Command{
 	Short: "list KCS instances",
 	Run: func(cmd *cobra.Command, args []string) {
 		filter, _ := cmd.Flags().GetString("filter")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
 		includeAll, _ := cmd.Flags().GetBool("all")
 
-		manager, err := ec2_instance.NewManager()
+		manager, err := ec2_instance.NewManager(awsConfig())
 		if err != nil {
 			log.Fatal(err)
 		}
+		manager.PageSize = pageSize
+
 		err = manager.FetchInstances(filter)
 		if err != nil {
 			log.Fatal(err)
@@ -34,11 +36,11 @@ var instanceListCmd = &cobra.Command{
 			return manager.Instances[i].Name < manager.Instances[j].Name
 		})
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Name", "ID", "Instance Age", "Status", "PublicIP", "PrivateIP"})
+		report := output.NewReport("Name", "ID", "Instance Age", "Status", "PublicIP", "PrivateIP")
+		report.Data = manager.Instances
 
 		for _, instance := range manager.Instances {
-			table.Append([]string{
+			report.Append([]string{
 				instance.Name,
 				instance.ID,
 				instance.InstanceAge,
@@ -48,13 +50,14 @@ var instanceListCmd = &cobra.Command{
 			})
 		}
 
-		table.Render()
+		render(report)
 	},
 }
 
 func init() {
 	instanceCmd.AddCommand(instanceListCmd)
 	instanceListCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	instanceListCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
 	instanceListCmd.Flags().BoolP("all", "a", false, "Include all statuses in the list")
 	instanceListCmd.Flags().Bool("ssm", false, "Only show instances with SSM enabled")
 	instanceListCmd.Flags().Bool("no-ssm", false, "Only show instances without SSM enabled")
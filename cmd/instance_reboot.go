@@ -8,27 +8,35 @@ import (
 )
 
 var instanceRebootCmd = &cobra.Command{
-	Use:   "reboot",
+	Use:   "reboot [instance]",
 	Short: "reboot an instance",
+	Long: `Reboot an instance, given by its Name tag or ID. Without an instance, one
+can be picked from the running instances on a terminal.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 
-		instanceID, _ := cmd.Flags().GetString("instance-id")
+		instance, err := selectInstance(instanceArg(cmd, args), false)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-		if instanceID == "" {
-			log.Fatalf("instance-id is required")
+		manager, err := ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		err := ec2_instance.Reboot(instanceID)
+		err = manager.Reboot(instance.ID)
 		if err != nil {
-			log.Fatalf("Unable to reboot instance %q, %v", instanceID, err)
+			log.Fatalf("Unable to reboot instance %s (%s), %v", instance.Name, instance.ID, err)
 		}
 
-		log.Printf("Successfully requested reboot for instance %q", instanceID)
+		log.Printf("Successfully requested reboot for instance %s (%s)", instance.Name, instance.ID)
 	},
 }
 
 func init() {
 	instanceCmd.AddCommand(instanceRebootCmd)
 
-	instanceRebootCmd.Flags().StringP("instance-id", "i", "", "the instance id to reboot")
+	addInstanceFlag(instanceRebootCmd, "the instance name or ID to reboot")
+	addInstanceIDFlag(instanceRebootCmd)
 }
@@ -2,11 +2,11 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"strconv"
+	"time"
 
 	"github.com/KineticCommerce/kci/ec2_instance"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 
 	_ "net/http/pprof"
@@ -17,18 +17,26 @@ var instanceScanCmd = &cobra.Command{
 	Short: "scan instances for OS and reboot status",
 	Run: func(cmd *cobra.Command, args []string) {
 		filter, _ := cmd.Flags().GetString("filter")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
 		includeAll, _ := cmd.Flags().GetBool("all")
 
 		// local filters and flags
-		jump, _ := cmd.Flags().GetString("jump")
-		jumpuser, _ := cmd.Flags().GetString("jumpuser")
 		rebootOnly, _ := cmd.Flags().GetBool("reboot-only")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		via, _ := cmd.Flags().GetString("via")
+
+		if via != "ssh" && via != "ssm" {
+			log.Fatalf("invalid --via %q, must be ssh or ssm", via)
+		}
 
 		// Get with main filters
-		manager, err := ec2_instance.NewManager()
+		manager, err := ec2_instance.NewManager(awsConfig())
 		if err != nil {
 			log.Fatal(err)
 		}
+		manager.PageSize = pageSize
+
 		err = manager.FetchInstances(filter)
 		if err != nil {
 			log.Fatal(err)
@@ -38,18 +46,44 @@ var instanceScanCmd = &cobra.Command{
 			manager.Filter(ec2_instance.IsRunningFilter)
 		}
 
-		// Filter and Calculate
-		// We pre-filter here because this is expensive and
-		// we are sure that we do not want to scan these on this pass.
-		manager.Filter(func(instance ec2_instance.EC2Instance) bool {
-			return len(instance.PublicIP) == 0
-		})
+		opts := ec2_instance.ScanOptions{
+			Concurrency: concurrency,
+			Timeout:     timeout,
+			Progress:    progress("scanned"),
+		}
 
-		// TODO move to package
-		//err = ScanInstances(jump, jumpuser, manager.Instances)
-		err = manager.JumpScan(jump, jumpuser)
-		if err != nil {
-			log.Fatal(err)
+		if via == "ssm" {
+			// SSM reaches public and private instances alike
+			err = manager.FetchSSMDetails()
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			err = manager.SSMScan(opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			// Filter and Calculate
+			// We pre-filter here because this is expensive and
+			// we are sure that we do not want to scan these on this pass.
+			manager.Filter(func(instance ec2_instance.EC2Instance) bool {
+				return len(instance.PublicIP) == 0
+			})
+
+			ssh, err := loadSSHSettings(cmd)
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts.Jump = ssh.Hops
+			opts.TargetUser = ssh.Env.ScanUser
+			opts.HostKeys = ssh.HostKeys
+			opts.Auth = ssh.Auth
+
+			err = manager.JumpScan(opts)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		if rebootOnly {
@@ -58,13 +92,13 @@ var instanceScanCmd = &cobra.Command{
 			})
 		}
 		// Display
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Name", "ID", "Instance Age", "Uptime", "Reboot", "Updates", "OS", "Private IP"})
+		report := output.NewReport("Name", "ID", "Instance Age", "Uptime", "Reboot", "Updates", "OS", "Private IP")
+		report.Data = manager.Instances
 
 		for _, instance := range manager.Instances {
 			updates := strconv.Itoa(instance.SecurityUpdates)
 
-			table.Append([]string{
+			report.Append([]string{
 				instance.Name,
 				instance.ID,
 				instance.InstanceAge,
@@ -76,15 +110,18 @@ var instanceScanCmd = &cobra.Command{
 			})
 		}
 
-		table.Render()
+		render(report)
 	},
 }
 
 func init() {
 	instanceCmd.AddCommand(instanceScanCmd)
 	instanceScanCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
-	instanceScanCmd.Flags().StringP("jump", "j", "", "jumpbox server address")
-	instanceScanCmd.Flags().StringP("jumpuser", "u", "", "jumpbox user")
+	instanceScanCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
 	instanceScanCmd.Flags().BoolP("all", "a", false, "Include all statuses in the list")
 	instanceScanCmd.Flags().Bool("reboot-only", false, "Display only servers that need a reboot")
+	instanceScanCmd.Flags().Int("concurrency", 10, "Number of instances to scan at once")
+	instanceScanCmd.Flags().Duration("timeout", time.Minute, "Maximum time to spend scanning a single instance")
+	instanceScanCmd.Flags().String("via", "ssh", "Scan over ssh through the bastion, or with SSM Run Command (ssm) without SSH keys")
+	addSSHFlags(instanceScanCmd)
 }
@@ -2,30 +2,45 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/KineticCommerce/kci/ec2_instance"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
 var instanceSSMCmd = &cobra.Command{
 	Use:   "ssm",
 	Short: "list KCS instances that are managed by SSM",
+	Long:  ssmListLong,
 	Run:   listSSMCommand,
 }
 
+const ssmListLong = `List the running instances that are managed by SSM, with the ping status
+and version of their SSM Agent, their platform and the status of their
+associations. Use --connection-lost to find agents that stopped checking in
+and --agent-outdated to find agents with a newer version available.`
+
 func listSSMCommand(cmd *cobra.Command, args []string) {
 	filter, _ := cmd.Flags().GetString("filter")
+	pageSize, _ := cmd.Flags().GetInt32("page-size")
 	disabled, _ := cmd.Flags().GetBool("disabled")
+	connectionLost, _ := cmd.Flags().GetBool("connection-lost")
+	agentOutdated, _ := cmd.Flags().GetBool("agent-outdated")
+
+	if disabled && (connectionLost || agentOutdated) {
+		log.Fatal("--disabled cannot be combined with --connection-lost or --agent-outdated")
+	}
 
 	// Get with main filters
-	manager, err := ec2_instance.NewManager()
+	manager, err := ec2_instance.NewManager(awsConfig())
 	if err != nil {
 		log.Fatal(err)
 	}
+	manager.PageSize = pageSize
+
 	err = manager.FetchInstances(filter)
 	if err != nil {
 		log.Fatal(err)
@@ -46,6 +61,13 @@ func listSSMCommand(cmd *cobra.Command, args []string) {
 		}
 	})
 
+	if connectionLost {
+		manager.Filter(ec2_instance.IsConnectionLostFilter)
+	}
+	if agentOutdated {
+		manager.Filter(ec2_instance.IsAgentOutdatedFilter)
+	}
+
 	// Display
 	sort.Slice(manager.Instances, func(i, j int) bool {
 		iAge, _ := strconv.Atoi(manager.Instances[i].InstanceAge)
@@ -53,23 +75,41 @@ func listSSMCommand(cmd *cobra.Command, args []string) {
 		return iAge < jAge
 	})
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Name", "ID", "SSM Enabled", "Status"})
+	report := output.NewReport("Name", "ID", "SSM Enabled", "Status", "Ping", "Last Ping", "Agent", "Outdated", "Platform", "Association")
+	report.Data = manager.Instances
 
 	for _, instance := range manager.Instances {
-		table.Append([]string{
+		row := []string{
 			instance.Name,
 			instance.ID,
 			strconv.FormatBool(instance.IsSSM),
 			instance.Status,
-		})
+			"", "", "", "", "", "",
+		}
+
+		if state := instance.SSM; state != nil {
+			row[4] = state.PingStatus
+			row[5] = "never"
+			if !state.LastPing.IsZero() {
+				row[5] = state.LastPing.Format("2006-01-02 15:04:05")
+			}
+			row[6] = state.AgentVersion
+			row[7] = strconv.FormatBool(state.AgentOutdated())
+			row[8] = strings.TrimSpace(state.PlatformName + " " + state.PlatformVersion)
+			row[9] = state.AssociationStatus
+		}
+
+		report.Append(row)
 	}
 
-	table.Render()
+	render(report)
 }
 
 func init() {
 	instanceCmd.AddCommand(instanceSSMCmd)
 	instanceSSMCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	instanceSSMCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
 	instanceSSMCmd.Flags().Bool("disabled", false, "Display SSM disabled instead")
+	instanceSSMCmd.Flags().Bool("connection-lost", false, "Display only instances whose SSM Agent lost its connection")
+	instanceSSMCmd.Flags().Bool("agent-outdated", false, "Display only instances with an outdated SSM Agent")
 }
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/KineticCommerce/kci/parameter"
+	"github.com/spf13/cobra"
+)
+
+var paramCmd = &cobra.Command{
+	Use:   "param",
+	Short: "Subcommands for the SSM Parameter Store of an environment",
+	Long: `Subcommands for the SSM Parameter Store of an environment.
+
+Parameter names are relative to the environment's param_prefix, "/<environment>"
+by default, so "db/password" in prod is /prod/db/password.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := cmd.Help()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// parameterManager returns a ParameterManager for the selected environment.
+func parameterManager() *parameter.ParameterManager {
+	env, err := settings.Lookup(environmentName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	manager, err := parameter.NewManager(awsConfig(), env.ParameterPrefix())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return manager
+}
+
+// displayValue returns the value to show for a parameter, hiding SecureString
+// values that were not decrypted.
+func displayValue(p parameter.Parameter) string {
+	if p.Type == parameter.TypeSecureString && p.Value == "" {
+		return "(encrypted)"
+	}
+
+	return p.Value
+}
+
+func init() {
+	rootCmd.AddCommand(paramCmd)
+}
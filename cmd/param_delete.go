@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var paramDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "delete a parameter and its history",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		manager := parameterManager()
+
+		name, err := manager.FullName(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = confirmProtected("Deleting "+name, yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = manager.Delete(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s deleted\n", name)
+	},
+}
+
+func init() {
+	paramCmd.AddCommand(paramDeleteCmd)
+	paramDeleteCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation in protected environments")
+}
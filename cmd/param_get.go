@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var paramGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "show a parameter",
+	Long: `Show a parameter. With --value only the value is printed, e.g. for use in
+scripts. SecureString values are only shown with --decrypt.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		decrypt, _ := cmd.Flags().GetBool("decrypt")
+		valueOnly, _ := cmd.Flags().GetBool("value")
+
+		p, err := parameterManager().Get(args[0], decrypt)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if valueOnly {
+			if p.Value == "" && !decrypt {
+				log.Fatalf("%s is a %s, use --decrypt to read its value", p.Name, p.Type)
+			}
+			fmt.Println(p.Value)
+			return
+		}
+
+		report := output.NewReport("Name", "Type", "Version", "Last Modified", "Value")
+		report.Data = p
+		report.Append([]string{
+			p.Name,
+			p.Type,
+			strconv.FormatInt(p.Version, 10),
+			p.LastModified.Format("2006-01-02 15:04:05"),
+			displayValue(p),
+		})
+
+		render(report)
+	},
+}
+
+func init() {
+	paramCmd.AddCommand(paramGetCmd)
+	paramGetCmd.Flags().Bool("decrypt", false, "Decrypt a SecureString value")
+	paramGetCmd.Flags().Bool("value", false, "Print only the value")
+}
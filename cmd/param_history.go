@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var paramHistoryCmd = &cobra.Command{
+	Use:   "history <name>",
+	Short: "list the versions of a parameter",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		decrypt, _ := cmd.Flags().GetBool("decrypt")
+
+		versions, err := parameterManager().History(args[0], decrypt)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		report := output.NewReport("Version", "Type", "Last Modified", "Modified By", "Value")
+		report.Data = versions
+
+		for _, p := range versions {
+			report.Append([]string{
+				strconv.FormatInt(p.Version, 10),
+				p.Type,
+				p.LastModified.Format("2006-01-02 15:04:05"),
+				p.ModifiedBy,
+				displayValue(p),
+			})
+		}
+
+		render(report)
+	},
+}
+
+func init() {
+	paramCmd.AddCommand(paramHistoryCmd)
+	paramHistoryCmd.Flags().Bool("decrypt", false, "Show the values of SecureString versions")
+}
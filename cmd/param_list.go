@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var paramListCmd = &cobra.Command{
+	Use:   "list [path]",
+	Short: "list the parameters below a path",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		decrypt, _ := cmd.Flags().GetBool("decrypt")
+
+		dir := ""
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		parameters, err := parameterManager().List(dir, recursive, decrypt)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		report := output.NewReport("Name", "Type", "Version", "Last Modified", "Value")
+		report.Data = parameters
+
+		for _, p := range parameters {
+			report.Append([]string{
+				p.Name,
+				p.Type,
+				strconv.FormatInt(p.Version, 10),
+				p.LastModified.Format("2006-01-02 15:04:05"),
+				displayValue(p),
+			})
+		}
+
+		render(report)
+	},
+}
+
+func init() {
+	paramCmd.AddCommand(paramListCmd)
+	paramListCmd.Flags().BoolP("recursive", "r", false, "Include parameters in nested paths")
+	paramListCmd.Flags().Bool("decrypt", false, "Show the values of SecureString parameters")
+}
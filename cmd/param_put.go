@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/KineticCommerce/kci/parameter"
+	"github.com/spf13/cobra"
+)
+
+var paramPutCmd = &cobra.Command{
+	Use:   "put <name> [value]",
+	Short: "create or update a parameter",
+	Long: `Create or update a parameter. The value is read from --value-file, or
+from standard input when it is not given as an argument, which keeps secrets
+out of the shell history.
+
+New parameters are Strings unless --type is given. An existing parameter is
+only replaced with --overwrite, and keeps its type. Changes to a protected
+environment ask for confirmation unless --yes is given.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		parameterType, _ := cmd.Flags().GetString("type")
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
+		valueFile, _ := cmd.Flags().GetString("value-file")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		switch parameterType {
+		case "", parameter.TypeString, parameter.TypeStringList, parameter.TypeSecureString:
+		default:
+			log.Fatalf("invalid --type %q, must be %s, %s or %s", parameterType, parameter.TypeString, parameter.TypeStringList, parameter.TypeSecureString)
+		}
+
+		value, err := parameterValue(args, valueFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager := parameterManager()
+
+		name, err := manager.FullName(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = confirmProtected("Writing "+name, yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		version, err := manager.Put(args[0], value, parameterType, overwrite)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Fprintf(os.Stderr, "%s is now at version %d\n", name, version)
+	},
+}
+
+// parameterValue returns the value from the arguments, a file or stdin. A
+// single trailing newline of a file or stdin is dropped.
+func parameterValue(args []string, valueFile string) (string, error) {
+	if len(args) == 2 {
+		if valueFile != "" {
+			return "", fmt.Errorf("give the value either as an argument or with --value-file")
+		}
+		return args[1], nil
+	}
+
+	var data []byte
+	var err error
+
+	if valueFile != "" {
+		data, err = os.ReadFile(valueFile)
+	} else {
+		data, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read value: %w", err)
+	}
+
+	value := strings.TrimSuffix(string(data), "\n")
+	if value == "" {
+		return "", fmt.Errorf("parameter values cannot be empty")
+	}
+
+	return value, nil
+}
+
+func init() {
+	paramCmd.AddCommand(paramPutCmd)
+	paramPutCmd.Flags().String("type", "", "Parameter type: String, StringList or SecureString (default String, or the existing type)")
+	paramPutCmd.Flags().Bool("overwrite", false, "Replace the value of an existing parameter")
+	paramPutCmd.Flags().String("value-file", "", "Read the value from this file")
+	paramPutCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation in protected environments")
+}
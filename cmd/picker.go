@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// pickerRows is the number of matches the picker shows at once.
+const pickerRows = 10
+
+// errPickerAborted is returned when the picker is left without a choice.
+var errPickerAborted = errors.New("nothing selected")
+
+// canPick reports whether the picker can be shown, i.e. whether stdin and
+// stderr are both terminals.
+func canPick() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// pick lets the user choose one of items by typing a fuzzy filter and moving
+// through the matches with the arrow keys, Ctrl-P and Ctrl-N. Enter selects
+// the highlighted match; Esc and Ctrl-C abort. It returns the index of the
+// chosen item.
+func pick(prompt string, items []string) (int, error) {
+	if len(items) == 0 {
+		return 0, errors.New("nothing to pick from")
+	}
+
+	in := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return 0, err
+	}
+	defer term.Restore(in, state)
+
+	reader := bufio.NewReader(os.Stdin)
+	var query []rune
+	selected := 0
+
+	for {
+		matches := fuzzyFilter(string(query), items)
+		if selected >= len(matches) {
+			selected = len(matches) - 1
+		}
+		if selected < 0 {
+			selected = 0
+		}
+
+		drawPicker(prompt, string(query), items, matches, selected)
+
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			clearPicker()
+			return 0, err
+		}
+
+		switch r {
+		case '\r', '\n':
+			if len(matches) > 0 {
+				clearPicker()
+				return matches[selected], nil
+			}
+		case 3: // Ctrl-C
+			clearPicker()
+			return 0, errPickerAborted
+		case 27: // Esc, or the start of an arrow key
+			if reader.Buffered() < 2 {
+				clearPicker()
+				return 0, errPickerAborted
+			}
+			reader.ReadRune()
+			code, _, _ := reader.ReadRune()
+			switch code {
+			case 'A':
+				selected--
+			case 'B':
+				selected++
+			}
+		case 16: // Ctrl-P
+			selected--
+		case 14: // Ctrl-N
+			selected++
+		case 127, 8: // Backspace
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				selected = 0
+			}
+		case 21: // Ctrl-U
+			query = query[:0]
+			selected = 0
+		default:
+			if unicode.IsPrint(r) {
+				query = append(query, r)
+				selected = 0
+			}
+		}
+	}
+}
+
+// drawPicker draws the prompt with the first matches below it, and leaves
+// the cursor at the end of the query.
+func drawPicker(prompt string, query string, items []string, matches []int, selected int) {
+	width, _, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	// keep the selection in view
+	first := 0
+	if selected >= pickerRows {
+		first = selected - pickerRows + 1
+	}
+	last := first + pickerRows
+	if last > len(matches) {
+		last = len(matches)
+	}
+
+	var b strings.Builder
+	b.WriteString("\r\x1b[J")
+	fmt.Fprintf(&b, "%s%s", prompt, query)
+
+	for i := first; i < last; i++ {
+		line := truncate(items[matches[i]], width-2)
+		if i == selected {
+			fmt.Fprintf(&b, "\r\n\x1b[7m> %s\x1b[0m", line)
+		} else {
+			fmt.Fprintf(&b, "\r\n  %s", line)
+		}
+	}
+	fmt.Fprintf(&b, "\r\n  %d/%d", len(matches), len(items))
+
+	fmt.Fprintf(&b, "\x1b[%dA\r", last-first+1)
+	if column := len([]rune(prompt)) + len([]rune(query)); column > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", column)
+	}
+
+	os.Stderr.WriteString(b.String())
+}
+
+// clearPicker removes the picker from the terminal.
+func clearPicker() {
+	os.Stderr.WriteString("\r\x1b[J")
+}
+
+// truncate shortens s to at most width runes.
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width < 0 || len(runes) <= width {
+		return s
+	}
+
+	return string(runes[:width])
+}
+
+// fuzzyFilter returns the indexes of the items matching query, best match
+// first. Items that score the same keep their order.
+func fuzzyFilter(query string, items []string) []int {
+	var matches []int
+	scores := map[int]int{}
+
+	for i, item := range items {
+		score, ok := fuzzyScore(query, item)
+		if ok {
+			matches = append(matches, i)
+			scores[i] = score
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return scores[matches[i]] > scores[matches[j]]
+	})
+
+	return matches
+}
+
+// fuzzyScore reports whether the runes of query appear in order in text,
+// ignoring case, and scores the match. Consecutive runes and runes at the
+// start of a word score higher.
+func fuzzyScore(query string, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	t := []rune(strings.ToLower(text))
+
+	score := 0
+	qi := 0
+	last := -2
+
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+
+		score++
+		if ti == last+1 {
+			score += 2
+		}
+		if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+			score += 3
+		}
+
+		last = ti
+		qi++
+	}
+
+	return score, qi == len(q)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query     string
+		text      string
+		wantScore int
+		wantOK    bool
+	}{
+		{"", "web-1", 0, true},
+		{"web", "web-1", 10, true},
+		{"WEB", "web-1", 10, true},
+		{"w1", "web-1", 8, true},
+		{"eb", "web-1", 4, true},
+		{"bew", "web-1", 0, false},
+		{"web-2", "web-1", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query+" "+tt.text, func(t *testing.T) {
+			score, ok := fuzzyScore(tt.query, tt.text)
+			if ok != tt.wantOK {
+				t.Fatalf("fuzzyScore(%q, %q) matched = %v, want %v", tt.query, tt.text, ok, tt.wantOK)
+			}
+			if ok && score != tt.wantScore {
+				t.Errorf("fuzzyScore(%q, %q) = %d, want %d", tt.query, tt.text, score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []string{"api-2", "web-1", "worker-b", "web-2", "db"}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2, 3, 4}},
+		{"web", []int{1, 3, 2}},
+		{"w2", []int{3}},
+		{"wb", []int{2, 1, 3}},
+		{"zz", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := fuzzyFilter(tt.query, items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fuzzyFilter(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"web-1", 10, "web-1"},
+		{"web-1", 3, "web"},
+		{"wéb-1", 2, "wé"},
+		{"web-1", -1, "web-1"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
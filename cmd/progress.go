@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// progress returns a progress callback that reports "<label> done/total" on
+// stderr when it is a terminal.
+func progress(label string) func(done int, total int) {
+	return func(done int, total int) {
+		info, err := os.Stderr.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d", label, done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KineticCommerce/kci/database"
+	"github.com/KineticCommerce/kci/ssh_jump"
+	"github.com/KineticCommerce/kci/ssm_session"
+	"github.com/spf13/cobra"
+)
+
+var rdsConnectCmd = &cobra.Command{
+	Use:   "connect <identifier> [-- psql arguments]",
+	Short: "open a psql session to a database through the bastion or SSM",
+	Long: `Open a psql session to a database through the bastion or SSM.
+
+The endpoint, port, database name and master user are looked up from the RDS
+instance and a local port is forwarded to it for as long as psql runs. With
+--via ssm the forward goes through an SSM managed instance in the same VPC
+instead of the bastion, with a port forwarding session per connection; the
+instance is given with --instance or picked on a terminal.
+Arguments after -- are passed on to psql.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		via, _ := cmd.Flags().GetString("via")
+		user, _ := cmd.Flags().GetString("user")
+		dbName, _ := cmd.Flags().GetString("dbname")
+
+		manager, err := database.NewManager(awsConfig())
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+
+		db, err := manager.FetchDatabase(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !strings.Contains(db.Engine, "postgres") {
+			log.Fatalf("%s is a %s database, only PostgreSQL is supported", db.ID, db.Engine)
+		}
+		if db.Endpoint == "" {
+			log.Fatalf("%s has no endpoint yet", db.ID)
+		}
+
+		if user == "" {
+			user = db.MasterUsername
+		}
+		if dbName == "" {
+			dbName = db.Name
+		}
+		if dbName == "" {
+			dbName = "postgres"
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var port int
+		var forward sync.WaitGroup
+
+		switch via {
+		case "bastion":
+			port, err = forwardBastion(ctx, cmd, &forward, db)
+		case "ssm":
+			port, err = forwardSSM(ctx, &forward, instanceArg(cmd, nil), db)
+		default:
+			err = fmt.Errorf("invalid --via %q, must be bastion or ssm", via)
+		}
+		if err != nil {
+			cancel()
+			forward.Wait()
+			log.Fatal(err)
+		}
+
+		err = runPsql(port, user, dbName, args[1:])
+
+		cancel()
+		forward.Wait()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// forwardBastion forwards a local port to the database through the jump hosts
+// until ctx is done, and returns the port.
+func forwardBastion(ctx context.Context, cmd *cobra.Command, forward *sync.WaitGroup, db database.DatabaseInfo) (int, error) {
+	ssh, err := loadSSHSettings(cmd)
+	if err != nil {
+		return 0, err
+	}
+	if len(ssh.Hops) == 0 {
+		return 0, errors.New("no jump host configured for the environment, use --jump or --via ssm")
+	}
+
+	pool, err := ssh_jump.NewPool(ssh.Hops, ssh.HostKeys, ssh.Auth)
+	if err != nil {
+		return 0, err
+	}
+
+	err = pool.Connect()
+	if err != nil {
+		pool.Close()
+		return 0, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		pool.Close()
+		return 0, fmt.Errorf("unable to listen for the forward: %w", err)
+	}
+
+	remote := net.JoinHostPort(db.Endpoint, strconv.Itoa(int(db.Port)))
+
+	forward.Add(1)
+	go func() {
+		defer forward.Done()
+		defer pool.Close()
+
+		err := pool.Forward(ctx, listener, remote)
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// forwardSSM forwards a local port to the database through an SSM managed
+// instance until ctx is done, and returns the port.
+func forwardSSM(ctx context.Context, forward *sync.WaitGroup, target string, db database.DatabaseInfo) (int, error) {
+	instance, err := selectInstance(target, true)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions, err := ssm_session.NewManager(awsConfig())
+	if err != nil {
+		return 0, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("unable to listen for the forward: %w", err)
+	}
+
+	forward.Add(1)
+	go func() {
+		defer forward.Done()
+
+		err := sessions.Forward(ctx, listener, instance.ID, db.Endpoint, strconv.Itoa(int(db.Port)))
+		if err != nil {
+			log.Print(err)
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// runPsql runs psql against the forwarded port, attached to the terminal.
+func runPsql(port int, user string, dbName string, extra []string) error {
+	args := append([]string{
+		"--host", "127.0.0.1",
+		"--port", strconv.Itoa(port),
+		"--username", user,
+		"--dbname", dbName,
+	}, extra...)
+
+	c := exec.Command("psql", args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	// Ctrl-C reaches psql from the terminal and cancels its query; kci must
+	// survive it to keep the forward open. Catching the signal rather than
+	// ignoring it leaves psql's own handling untouched.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err := c.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return errors.New("psql not found, install the PostgreSQL client")
+	}
+
+	return err
+}
+
+func init() {
+	rdsCmd.AddCommand(rdsConnectCmd)
+
+	rdsConnectCmd.Flags().String("via", "bastion", "Forward through the bastion or ssm")
+	addInstanceFlag(rdsConnectCmd, "SSM managed instance name or ID to forward through with --via ssm")
+	rdsConnectCmd.Flags().StringP("user", "U", "", "Database user, defaults to the master user")
+	rdsConnectCmd.Flags().String("dbname", "", "Database name, defaults to the database's initial database or postgres")
+	addSSHFlags(rdsConnectCmd)
+}
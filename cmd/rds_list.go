@@ -2,11 +2,10 @@ package cmd
 
 import (
 	"log"
-	"os"
 	"strconv"
 
 	"github.com/KineticCommerce/kci/database"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
@@ -15,30 +14,33 @@ var rdsListCmd = &cobra.Command{
 	Short: "list KCS RDS databases",
 	Run: func(cmd *cobra.Command, args []string) {
 		filter, _ := cmd.Flags().GetString("filter")
-		manager, err := database.NewManager()
+		maxSnapshots, _ := cmd.Flags().GetInt("max-snapshots")
+
+		manager, err := database.NewManager(awsConfig())
 		if err != nil {
 			log.Fatalf("unable to load SDK config, %v", err)
 		}
+		manager.MaxSnapshots = maxSnapshots
 
 		err = manager.Fetch(filter)
 		if err != nil {
 			log.Fatalf("unlable to load databases: %v", err)
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"ID", "Multi AZ", "Latest Snapshot ID", "Snapshot Count"})
+		report := output.NewReport("ID", "Multi AZ", "Latest Snapshot ID", "Snapshot Count")
+		report.Data = manager.Databases
 
 		for _, db := range manager.Databases {
 
-			table.Append([]string{
+			report.Append([]string{
 				db.ID,
 				strconv.FormatBool(db.MultiAZ),
 				db.LatestSnapshotID(),
-				strconv.Itoa(len(db.Snapshots)),
+				strconv.Itoa(db.SnapshotCount),
 			})
 		}
 
-		table.Render()
+		render(report)
 	},
 }
 
@@ -46,4 +48,5 @@ func init() {
 	rdsCmd.AddCommand(rdsListCmd)
 
 	rdsListCmd.Flags().StringP("filter", "f", "", "Filter databases by name")
+	rdsListCmd.Flags().Int("max-snapshots", 0, "Maximum number of snapshots to load per database, newest first (0 for all)")
 }
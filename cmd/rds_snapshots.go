@@ -3,10 +3,9 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/KineticCommerce/kci/database"
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
@@ -15,34 +14,41 @@ var rdsSnapshotCmd = &cobra.Command{
 	Short: "list snapshots for a database",
 	Run: func(cmd *cobra.Command, args []string) {
 		identifier, _ := cmd.Flags().GetString("identifier")
+		maxSnapshots, _ := cmd.Flags().GetInt("max-snapshots")
 
-		manager, err := database.NewManager()
+		manager, err := database.NewManager(awsConfig())
 		if err != nil {
 			log.Fatalf("unable to load database manager %v", err)
 		}
+		manager.MaxSnapshots = maxSnapshots
 
 		snapshots, err := manager.FetchSnapshots(identifier)
 		if err != nil {
 			log.Fatalf("unlable to load database %s: %v", identifier, err)
 		}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"ID", "Created At", "Size (GiB)"})
+		report := output.NewReport("ID", "Created At", "Size (GiB)")
+		report.Data = snapshots
 
 		for _, snapshot := range snapshots {
+			created := snapshot.Status
+			if !snapshot.Creating() {
+				created = snapshot.Created.Format("2006-01-02 15:04:05")
+			}
 
-			table.Append([]string{
+			report.Append([]string{
 				snapshot.ID,
-				snapshot.Created.Format("2006-01-02 15:04:05"),
+				created,
 				fmt.Sprint(snapshot.Size),
 			})
 		}
 
-		table.Render()
+		render(report)
 	},
 }
 
 func init() {
 	rdsCmd.AddCommand(rdsSnapshotCmd)
 	rdsSnapshotCmd.Flags().StringP("identifier", "i", "", "database identifier")
+	rdsSnapshotCmd.Flags().Int("max-snapshots", 0, "Maximum number of snapshots to show, newest first (0 for all)")
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/KineticCommerce/kci/replay"
+)
+
+var (
+	recordDir  string
+	replayDir  string
+	httpClient = http.DefaultClient
+)
+
+// setupRecording points the shared HTTP client at a recorder or player when
+// --record or --replay is given.
+func setupRecording() error {
+	if recordDir != "" && replayDir != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
+	}
+
+	if recordDir != "" {
+		recorder, err := replay.NewRecorder(recordDir, http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		log.Printf("recording to %s: credentials and SecureString values are redacted, but everything else is saved in clear text", recordDir)
+		httpClient = &http.Client{Transport: recorder}
+	}
+
+	if replayDir != "" {
+		player, err := replay.NewPlayer(replayDir)
+		if err != nil {
+			return err
+		}
+		httpClient = &http.Client{Transport: player}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Record all AWS and HTTP responses to this directory")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "", "Replay AWS and HTTP responses from a directory recorded with --record, without network access")
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls resize whenever the terminal is resized, until done is
+// closed.
+func watchResize(done <-chan struct{}, resize func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-signals:
+			resize()
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package cmd
+
+import (
+	"time"
+)
+
+// watchResize calls resize periodically until done is closed, since Windows
+// consoles do not signal resizes.
+func watchResize(done <-chan struct{}, resize func()) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			resize()
+		}
+	}
+}
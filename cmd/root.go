@@ -2,16 +2,25 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"strings"
 
+	"github.com/KineticCommerce/kci/environment"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
 	debug             bool
 	verbose           bool
-	environment       string
-	validEnvironments = []string{"dit", "stage", "prod", "prod-eu"}
+	environmentName   string
+	outputFormat      string
+	format            output.Format
+	settings          *environment.Config
+	settingsErr       error
+	validEnvironments []string
 	BuildTime         = "not set"
 )
 
@@ -31,24 +40,123 @@ var rootCmd = &cobra.Command{
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if !isValidEnvironment(environment) {
-			return fmt.Errorf("Invalid environment: '%v'. Environment can be 'dit', 'stage', or 'prod'", environment)
+		// validate reports configuration errors itself
+		if cmd == configValidateCmd {
+			return nil
 		}
+		if settingsErr != nil {
+			if !needsSettings(cmd) {
+				return nil
+			}
+			return settingsErr
+		}
+
+		err := applyDefaults(cmd)
+		if err != nil {
+			return err
+		}
+
+		err = setupRecording()
+		if err != nil {
+			return err
+		}
+
+		if environmentName == "" {
+			environmentName = validEnvironments[0]
+		}
+		if !isValidEnvironment(environmentName) {
+			return fmt.Errorf("Invalid environment: '%v'. Environment can be one of: %s", environmentName, strings.Join(validEnvironments, ", "))
+		}
+
+		format, err = output.ParseFormat(outputFormat)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	},
 }
 
+// needsSettings reports whether a command needs the configuration file. Help,
+// version and shell completion keep working when it is broken.
+func needsSettings(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "version", "help", cobra.ShellCompRequestCmd:
+		return false
+	}
+
+	return !cmd.HasParent() || cmd.Parent().Name() != "completion"
+}
+
+// applyDefaults sets the flags that were not given on the command line from the
+// "global" and per-command defaults of the config file. Per-command defaults
+// win over global ones.
+func applyDefaults(cmd *cobra.Command) error {
+	explicit := map[string]bool{}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		explicit[flag.Name] = true
+	})
+
+	path := commandPath(cmd)
+	for _, key := range []string{"global", path} {
+		for name, value := range settings.Defaults[key] {
+			if explicit[name] {
+				continue
+			}
+
+			if cmd.Flags().Lookup(name) == nil {
+				if key == "global" {
+					continue
+				}
+				return fmt.Errorf("config default %q is not a flag of %q", name, path)
+			}
+
+			err := cmd.Flags().Set(name, value)
+			if err != nil {
+				return fmt.Errorf("invalid config default for %q: %w", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// commandPath returns the path of a command without the root command name,
+// e.g. "instance scan". This is the key used for per-command defaults.
+func commandPath(cmd *cobra.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()), " ")
+}
+
+// render writes a report to stdout in the format selected with --output.
+func render(report *output.Report) {
+	err := report.Render(os.Stdout, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 func Execute(buildTime string) {
 	BuildTime = buildTime
 
-	err := rootCmd.Execute()
+	path, err := environment.ConfigPath()
+	if err == nil {
+		settings, err = environment.LoadConfig(path)
+	}
+	if err != nil {
+		settingsErr = err
+	} else {
+		validEnvironments = settings.Names()
+	}
+
+	err = rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&environment, "environment", "e", "dit", "Set the environment. Can be 'dit', 'stage', or 'prod'")
+	rootCmd.PersistentFlags().StringVarP(&environmentName, "environment", "e", "", "Set the environment, see 'kci config show'. Defaults to the first configured environment")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format. One of: "+output.FormatNames())
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug output")
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"github.com/KineticCommerce/kci/environment"
+	"github.com/KineticCommerce/kci/ssh_jump"
+	"github.com/spf13/cobra"
+)
+
+// sshSettings are the SSH options shared by commands that connect through the
+// jump hosts.
+type sshSettings struct {
+	Env      environment.Environment
+	Hops     []ssh_jump.Hop
+	HostKeys *ssh_jump.HostKeys
+	Auth     *ssh_jump.Auth
+}
+
+// addSSHFlags adds the jump host, authentication and host key flags.
+func addSSHFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("jump", "j", "", "jumpbox server address or ~/.ssh/config alias, or a comma separated chain of them; defaults to the environment's jump_host")
+	cmd.Flags().StringP("jumpuser", "u", "", "jumpbox user for hops that do not name one, defaults to the environment's jump_user or ~/.ssh/config")
+	cmd.Flags().StringSlice("identity", nil, "SSH private key file to authenticate with, may be repeated")
+	cmd.Flags().StringSlice("auth", ssh_jump.DefaultAuthOrder, "SSH authentication methods to try, in order: agent, key, password")
+	cmd.Flags().Bool("accept-new", false, "Trust and remember the host keys of hosts not yet in known_hosts")
+}
+
+// loadSSHSettings reads the flags added by addSSHFlags, falling back to the
+// jump host of the environment.
+func loadSSHSettings(cmd *cobra.Command) (sshSettings, error) {
+	jump, _ := cmd.Flags().GetString("jump")
+	jumpuser, _ := cmd.Flags().GetString("jumpuser")
+	acceptNew, _ := cmd.Flags().GetBool("accept-new")
+	identities, _ := cmd.Flags().GetStringSlice("identity")
+	authOrder, _ := cmd.Flags().GetStringSlice("auth")
+
+	var ss sshSettings
+	var err error
+
+	ss.Env, err = settings.Lookup(environmentName)
+	if err != nil {
+		return ss, err
+	}
+	if jump == "" {
+		jump = ss.Env.JumpHost
+	}
+	if jumpuser == "" {
+		jumpuser = ss.Env.JumpUser
+	}
+
+	ss.Hops, err = ssh_jump.ParseChain(jump, jumpuser)
+	if err != nil {
+		return ss, err
+	}
+
+	ss.HostKeys, err = ssh_jump.NewHostKeys(acceptNew)
+	if err != nil {
+		return ss, err
+	}
+
+	ss.Auth, err = ssh_jump.NewAuth(authOrder, identities)
+	if err != nil {
+		return ss, err
+	}
+	ss.Auth.Prompt = ssh_jump.TerminalPrompt
+
+	return ss, nil
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/KineticCommerce/kci/ec2_instance"
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var ssmDiagnoseCmd = &cobra.Command{
+	Use:   "diagnose",
+	Short: "explain why instances are not managed by SSM",
+	Long: `Explain why running instances are not managed by SSM, or have lost their
+connection. For each instance kci checks:
+
+  - that an IAM instance profile is attached, unless Default Host Management
+    Configuration is enabled
+  - that a role of the profile has the AmazonSSMManagedInstanceCore policy
+  - that the instance can reach SSM, through the ssm, ssmmessages and
+    ec2messages VPC endpoints or the default route of its subnet
+  - whether the instance ever registered with SSM
+
+The Reason column says what to fix. Security groups, network ACLs and custom
+IAM policies are not checked. Only instances with a problem are shown unless
+--all is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, _ := cmd.Flags().GetString("filter")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
+		all, _ := cmd.Flags().GetBool("all")
+
+		tagFilters, err := ec2_instance.ParseTagFilters(tags)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager, err := ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+		manager.PageSize = pageSize
+		manager.TagFilters = tagFilters
+
+		err = manager.FetchInstances(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager.Filter(ec2_instance.IsRunningFilter)
+
+		diagnoses, err := manager.Diagnose()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if !all {
+			j := 0
+			for _, d := range diagnoses {
+				if !d.Healthy() {
+					diagnoses[j] = d
+					j++
+				}
+			}
+			diagnoses = diagnoses[:j]
+		}
+
+		sort.Slice(diagnoses, func(i, j int) bool {
+			return diagnoses[i].Name < diagnoses[j].Name
+		})
+
+		report := output.NewReport("Name", "ID", "Ping", "Profile", "Core Policy", "Network", "Reason")
+		report.Data = diagnoses
+
+		for _, d := range diagnoses {
+			ping := d.PingStatus
+			if ping == "" {
+				ping = "never registered"
+			}
+
+			corePolicy := ""
+			if d.InstanceProfile != "" {
+				corePolicy = yesNo(d.CorePolicy)
+			}
+
+			report.Append([]string{
+				d.Name,
+				d.InstanceID,
+				ping,
+				d.InstanceProfile,
+				corePolicy,
+				d.Network,
+				strings.Join(d.Reasons, "; "),
+			})
+		}
+
+		render(report)
+	},
+}
+
+// yesNo formats a check result.
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func init() {
+	ssmCmd.AddCommand(ssmDiagnoseCmd)
+	ssmDiagnoseCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	ssmDiagnoseCmd.Flags().StringArrayP("tag", "t", nil, "Filter instances by tag, KEY=VALUE with * and ? wildcards, may be repeated")
+	ssmDiagnoseCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
+	ssmDiagnoseCmd.Flags().Bool("all", false, "Display healthy instances too")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/KineticCommerce/kci/ssh_jump"
+	"github.com/KineticCommerce/kci/ssm_session"
+	"github.com/spf13/cobra"
+)
+
+var ssmForwardCmd = &cobra.Command{
+	Use:   "forward [-i instance] -L [bind_address:]port:target[:port] ...",
+	Short: "Forward local ports through an SSM managed instance",
+	Long: `Forward local ports through an SSM managed instance, without a bastion.
+
+Each connection gets a port forwarding session of its own. The target may be
+an RDS instance identifier, an instance Name tag or ID, a host name or IP
+address as seen from the instance, or localhost for a port on the instance
+itself. The port may be left out for databases, which default to the port
+they listen on. Without -i, the instance can be picked from the SSM managed
+instances on a terminal.
+
+  kci ssm forward -i web-1 -L 5432:orders-db -L 8080:localhost:80
+
+Forwards run until interrupted with Ctrl-C.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		specs, _ := cmd.Flags().GetStringArray("local")
+		if len(specs) == 0 {
+			log.Fatal("at least one forward (-L) is required")
+		}
+
+		resolver := &targetResolver{}
+
+		var forwards []ssh_jump.Forward
+		for _, spec := range specs {
+			fwd, err := ssh_jump.ParseForward(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if isLocalhost(fwd.Host) {
+				if fwd.Port == "" {
+					log.Fatalf("forward to %s needs a port", fwd.Host)
+				}
+				fwd.Host = ""
+			} else {
+				fwd, err = resolver.resolve(fwd)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			forwards = append(forwards, fwd)
+		}
+
+		instance, err := selectInstance(instanceArg(cmd, nil), true)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sessions, err := ssm_session.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var listeners []net.Listener
+		for _, fwd := range forwards {
+			listener, err := net.Listen("tcp", fwd.LocalAddress())
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				log.Fatalf("unable to listen on %s: %v", fwd.LocalAddress(), err)
+			}
+
+			listeners = append(listeners, listener)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var wg sync.WaitGroup
+		for i, fwd := range forwards {
+			remote := fwd.RemoteAddress()
+			if fwd.Host == "" {
+				remote = "port " + fwd.Port
+			}
+			fmt.Fprintf(os.Stderr, "Forwarding %s to %s on %s (%s)\n", listeners[i].Addr(), remote, instance.Name, instance.ID)
+
+			wg.Add(1)
+			go func(listener net.Listener, fwd ssh_jump.Forward) {
+				defer wg.Done()
+
+				err := sessions.Forward(ctx, listener, instance.ID, fwd.Host, fwd.Port)
+				if err != nil {
+					log.Print(err)
+				}
+			}(listeners[i], fwd)
+		}
+
+		fmt.Fprintln(os.Stderr, "Press Ctrl-C to stop")
+		wg.Wait()
+	},
+}
+
+// isLocalhost reports whether a forward targets the instance itself.
+func isLocalhost(host string) bool {
+	return host == "localhost" || net.ParseIP(host).IsLoopback()
+}
+
+func init() {
+	ssmCmd.AddCommand(ssmForwardCmd)
+	addInstanceFlag(ssmForwardCmd, "SSM managed instance name or ID to forward through")
+	ssmForwardCmd.Flags().StringArrayP("local", "L", nil, "Forward [bind_address:]port:target[:port], may be repeated")
+}
@@ -5,6 +5,7 @@ import "github.com/spf13/cobra"
 var ssmListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "list KCS instances with SSM enabled",
+	Long:  ssmListLong,
 	Run:   listSSMCommand,
 }
 
@@ -12,5 +13,8 @@ func init() {
 	// this SHOULD work as a simple alias
 	ssmCmd.AddCommand(ssmListCmd)
 	ssmListCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	ssmListCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
 	ssmListCmd.Flags().Bool("disabled", false, "Display SSM disabled instead")
+	ssmListCmd.Flags().Bool("connection-lost", false, "Display only instances whose SSM Agent lost its connection")
+	ssmListCmd.Flags().Bool("agent-outdated", false, "Display only instances with an outdated SSM Agent")
 }
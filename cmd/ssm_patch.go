@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/KineticCommerce/kci/ec2_instance"
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+var ssmPatchCmd = &cobra.Command{
+	Use:   "patch",
+	Short: "report the patch compliance of KCS instances",
+	Long: `Report the patch compliance of running instances as last recorded by SSM
+Patch Manager: missing and failed patches, patches installed but waiting for a
+reboot, non-compliant critical and security patches, and the last patch
+operation. Instances that were never scanned are reported as such.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, _ := cmd.Flags().GetString("filter")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
+		nonCompliant, _ := cmd.Flags().GetBool("non-compliant")
+
+		tagFilters, err := ec2_instance.ParseTagFilters(tags)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager, err := ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+		manager.PageSize = pageSize
+		manager.TagFilters = tagFilters
+
+		err = manager.FetchInstances(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager.Filter(ec2_instance.IsRunningFilter)
+
+		err = manager.FetchPatchStates()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if nonCompliant {
+			manager.Filter(func(instance ec2_instance.EC2Instance) bool {
+				return instance.Patch == nil || !instance.Patch.Compliant()
+			})
+		}
+
+		sort.Slice(manager.Instances, func(i, j int) bool {
+			return manager.Instances[i].Name < manager.Instances[j].Name
+		})
+
+		report := output.NewReport("Name", "ID", "Missing", "Failed", "Pending Reboot", "Critical", "Security", "Last Operation", "Operation Time")
+		report.Data = manager.Instances
+
+		for _, instance := range manager.Instances {
+			state := instance.Patch
+			if state == nil {
+				report.Append([]string{instance.Name, instance.ID, "", "", "", "", "", "never scanned", ""})
+				continue
+			}
+
+			report.Append([]string{
+				instance.Name,
+				instance.ID,
+				strconv.Itoa(int(state.Missing)),
+				strconv.Itoa(int(state.Failed)),
+				strconv.Itoa(int(state.InstalledPendingReboot)),
+				strconv.Itoa(int(state.CriticalNonCompliant)),
+				strconv.Itoa(int(state.SecurityNonCompliant)),
+				state.Operation,
+				state.OperationEndTime.Format("2006-01-02 15:04:05"),
+			})
+		}
+
+		render(report)
+	},
+}
+
+func init() {
+	ssmCmd.AddCommand(ssmPatchCmd)
+	ssmPatchCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	ssmPatchCmd.Flags().StringArrayP("tag", "t", nil, "Filter instances by tag, KEY=VALUE with * and ? wildcards, may be repeated")
+	ssmPatchCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
+	ssmPatchCmd.Flags().Bool("non-compliant", false, "Display only instances that are missing patches, failed to install them, need a reboot or were never scanned")
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KineticCommerce/kci/ec2_instance"
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+// ssmRunResult is the outcome of ssm run on one instance.
+type ssmRunResult struct {
+	Name string `json:"name"`
+	ec2_instance.CommandResult
+}
+
+var ssmRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "run a shell command or script on SSM managed instances",
+	Long: `Run a shell command or a local script on SSM managed instances with
+AWS-RunShellScript. Commands run as root.
+
+Instances are selected with --filter on their name and --tag on their tags;
+only running, SSM managed instances are targeted. The status, exit code and
+first line of output of every instance are reported, or everything with -o json.
+With --output-dir the full stdout and stderr of each instance are saved to
+<dir>/<instance id>/.
+
+--max-concurrency and --max-errors limit all selected instances together.
+SSM takes 50 instances per command, so larger fleets are run in batches of 50,
+one after another; once more than --max-errors instances have failed, the
+remaining batches are skipped.
+
+Running in a protected environment asks for confirmation unless --yes is
+given.
+
+  kci ssm run --tag Role=web --command 'systemctl is-active nginx'
+  kci ssm run --filter worker --script ./cleanup.sh --max-concurrency 25%`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, _ := cmd.Flags().GetString("filter")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
+		command, _ := cmd.Flags().GetString("command")
+		script, _ := cmd.Flags().GetString("script")
+		maxConcurrency, _ := cmd.Flags().GetString("max-concurrency")
+		maxErrors, _ := cmd.Flags().GetString("max-errors")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		outputDir, _ := cmd.Flags().GetString("output-dir")
+		all, _ := cmd.Flags().GetBool("all")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if (command == "") == (script == "") {
+			log.Fatal("exactly one of --command or --script is required")
+		}
+		if script != "" {
+			content, err := os.ReadFile(script)
+			if err != nil {
+				log.Fatalf("unable to read script: %v", err)
+			}
+			command = string(content)
+		}
+
+		tagFilters, err := ec2_instance.ParseTagFilters(tags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if filter == "" && len(tagFilters) == 0 && !all {
+			log.Fatal("select instances with --filter or --tag, or target every instance with --all")
+		}
+
+		manager, err := ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+		manager.PageSize = pageSize
+		manager.TagFilters = tagFilters
+
+		err = manager.FetchInstances(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager.Filter(ec2_instance.IsRunningFilter)
+
+		err = manager.FetchSSMDetails()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var ids []string
+		names := map[string]string{}
+		for _, instance := range manager.Instances {
+			if !instance.IsSSM {
+				log.Printf("skipping %s (%s): not managed by SSM", instance.Name, instance.ID)
+				continue
+			}
+
+			ids = append(ids, instance.ID)
+			names[instance.ID] = instance.Name
+		}
+		if len(ids) == 0 {
+			log.Fatal("no SSM managed instances match")
+		}
+
+		err = confirmProtected("Running a command on "+strconv.Itoa(len(ids))+" instances", yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		results, err := manager.RunCommand(ids, ec2_instance.CommandRequest{
+			Commands:       []string{command},
+			Comment:        "kci ssm run",
+			Timeout:        timeout,
+			MaxConcurrency: maxConcurrency,
+			MaxErrors:      maxErrors,
+			Progress:       progress("finished"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		failed := false
+		report := output.NewReport("Name", "ID", "Status", "Exit Code", "Output")
+		data := make([]ssmRunResult, len(results))
+
+		for i, result := range results {
+			data[i] = ssmRunResult{Name: names[result.InstanceID], CommandResult: result}
+
+			if result.Err != nil {
+				log.Printf("%s (%s): %v", data[i].Name, result.InstanceID, result.Err)
+			}
+			if result.Status != "Success" {
+				failed = true
+			}
+
+			if outputDir != "" {
+				err = saveRunResult(outputDir, result)
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			report.Append([]string{
+				data[i].Name,
+				result.InstanceID,
+				result.Status,
+				strconv.Itoa(int(result.ExitCode)),
+				firstLine(result.Output, result.Error),
+			})
+		}
+		report.Data = data
+
+		render(report)
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// saveRunResult writes the stdout and stderr of a result to
+// <dir>/<instance id>/.
+func saveRunResult(dir string, result ec2_instance.CommandResult) error {
+	instanceDir := filepath.Join(dir, result.InstanceID)
+
+	err := os.MkdirAll(instanceDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", instanceDir, err)
+	}
+
+	err = os.WriteFile(filepath.Join(instanceDir, "stdout"), []byte(result.Output), 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to save output: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(instanceDir, "stderr"), []byte(result.Error), 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to save output: %w", err)
+	}
+
+	return nil
+}
+
+// firstLine returns the first non-empty line of the first output that has
+// one.
+func firstLine(outputs ...string) string {
+	for _, output := range outputs {
+		for _, line := range strings.Split(output, "\n") {
+			if strings.TrimSpace(line) != "" {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+
+	return ""
+}
+
+func init() {
+	ssmCmd.AddCommand(ssmRunCmd)
+	ssmRunCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	ssmRunCmd.Flags().StringArrayP("tag", "t", nil, "Filter instances by tag, KEY=VALUE with * and ? wildcards, may be repeated")
+	ssmRunCmd.Flags().Bool("all", false, "Run on every SSM managed instance")
+	ssmRunCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
+	ssmRunCmd.Flags().StringP("command", "c", "", "Shell command to run")
+	ssmRunCmd.Flags().String("script", "", "Local shell script file to run")
+	ssmRunCmd.Flags().String("max-concurrency", "10", "Maximum number or percentage of all instances running the command at once")
+	ssmRunCmd.Flags().String("max-errors", "0", "Number or percentage of all instances that may fail before no more are started")
+	ssmRunCmd.Flags().Duration("timeout", 10*time.Minute, "Maximum time the command may run on each instance")
+	ssmRunCmd.Flags().String("output-dir", "", "Save the full output of each instance to this directory")
+	ssmRunCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation in protected environments")
+}
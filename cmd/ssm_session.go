@@ -1,37 +1,143 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"time"
 
+	"github.com/KineticCommerce/kci/ssm_session"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var ssmSessionCmd = &cobra.Command{
-	Use:   "session",
-	Short: "Start an SSM session for a given instance. AWS CLI required.",
+	Use:   "session [instance]",
+	Short: "Start an SSM session for a given instance",
+	Long: `Start an interactive shell on an instance with SSM Session Manager.
+
+The instance is given by its Name tag or ID. Without an instance, one can be
+picked from the SSM managed instances on a terminal.
+
+The session is handled by kci itself, so neither the AWS CLI nor the
+session-manager-plugin is needed. KMS encrypted sessions are not supported
+natively and fall back to 'aws ssm start-session', as does --aws-cli.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		instanceID, _ := cmd.Flags().GetString("instance-id")
-		if instanceID == "" {
-			log.Fatalf("instance-id is a required parameter")
+		useCLI, _ := cmd.Flags().GetBool("aws-cli")
+
+		instance, err := selectInstance(instanceArg(cmd, args), true)
+		if err != nil {
+			log.Fatal(err)
 		}
+		instanceID := instance.ID
+
+		if !useCLI {
+			err := nativeSession(instanceID)
+			if !errors.Is(err, ssm_session.ErrEncryptionUnsupported) {
+				if err != nil {
+					log.Fatal(err)
+				}
+				return
+			}
 
-		c := exec.Command("aws", "ssm", "start-session", "--target", instanceID)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		c.Stdin = os.Stdin
+			log.Printf("%v, falling back to the AWS CLI", err)
+		}
 
-		// Run the command. This will block until the session is finished.
-		err := c.Run()
+		err = cliSession(instanceID)
 		if err != nil {
 			log.Fatal(err)
 		}
 	},
 }
 
+// nativeSession runs a shell session on the instance, connected to the
+// terminal, until it ends.
+func nativeSession(instanceID string) error {
+	manager, err := ssm_session.NewManager(awsConfig())
+	if err != nil {
+		return err
+	}
+	manager.Messages = crlfWriter{os.Stderr}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	session, err := manager.Start(ctx, instanceID, "", nil)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin := int(os.Stdin.Fd())
+	if term.IsTerminal(stdin) {
+		state, err := term.MakeRaw(stdin)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(stdin, state)
+
+		resize := func() {
+			cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+			if err == nil {
+				session.SetSize(cols, rows)
+			}
+		}
+		resize()
+		go watchResize(session.Done(), resize)
+	}
+
+	go io.Copy(session, os.Stdin)
+
+	_, err = io.Copy(os.Stdout, session)
+	if err != nil {
+		return err
+	}
+
+	return session.Wait()
+}
+
+// cliSession runs a shell session with the AWS CLI and the
+// session-manager-plugin.
+func cliSession(instanceID string) error {
+	env, err := awsCLIEnv()
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command("aws", "ssm", "start-session", "--target", instanceID)
+	c.Env = env
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Stdin = os.Stdin
+
+	// Run the command. This will block until the session is finished.
+	return c.Run()
+}
+
+// crlfWriter writes line breaks as CRLF, so that messages stay readable
+// while the terminal is in raw mode.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (cw crlfWriter) Write(p []byte) (int, error) {
+	_, err := cw.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n")))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
 func init() {
 	// this SHOULD work as a simple alias
 	ssmCmd.AddCommand(ssmSessionCmd)
-	ssmSessionCmd.Flags().StringP("instance-id", "i", "", "The instance to connect to - required")
+	addInstanceFlag(ssmSessionCmd, "The instance name or ID to connect to")
+	addInstanceIDFlag(ssmSessionCmd)
+	ssmSessionCmd.Flags().Bool("aws-cli", false, "Use 'aws ssm start-session' instead of the builtin client")
 }
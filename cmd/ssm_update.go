@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/KineticCommerce/kci/ec2_instance"
+	"github.com/KineticCommerce/kci/output"
+	"github.com/spf13/cobra"
+)
+
+// ssmUpdateResult is the outcome of ssm update on one instance, with its
+// patch state afterwards.
+type ssmUpdateResult struct {
+	Name string `json:"name"`
+	ec2_instance.CommandResult
+	Patch *ec2_instance.PatchState `json:"patch,omitempty"`
+}
+
+var ssmUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "apply patches to SSM managed instances with AWS-RunPatchBaseline",
+	Long: `Apply patches to SSM managed instances with AWS-RunPatchBaseline.
+
+Instances are selected with --filter, --tag or --all. The install operation
+applies the missing patches of each instance's patch baseline; the scan
+operation only refreshes the compliance report of 'kci ssm patch'. Instances
+are not rebooted unless --reboot is given, in which case they are rebooted
+when an installed patch needs it.
+
+--max-concurrency and --max-errors limit all selected instances together.
+SSM takes 50 instances per command, so larger fleets are patched in batches of
+50, one after another; once more than --max-errors instances have failed, the
+remaining batches are skipped.
+
+Use --dry-run to list the instances that would be patched and what they are
+missing. Installing patches in a protected environment asks for confirmation
+unless --yes is given. A summary of what was patched and what still needs a
+reboot is shown at the end.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, _ := cmd.Flags().GetString("filter")
+		tags, _ := cmd.Flags().GetStringArray("tag")
+		all, _ := cmd.Flags().GetBool("all")
+		pageSize, _ := cmd.Flags().GetInt32("page-size")
+		operation, _ := cmd.Flags().GetString("operation")
+		reboot, _ := cmd.Flags().GetBool("reboot")
+		maxConcurrency, _ := cmd.Flags().GetString("max-concurrency")
+		maxErrors, _ := cmd.Flags().GetString("max-errors")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		var patchOperation string
+		switch operation {
+		case "install":
+			patchOperation = "Install"
+		case "scan":
+			patchOperation = "Scan"
+			if reboot {
+				log.Fatal("--reboot only applies to the install operation")
+			}
+		default:
+			log.Fatalf("invalid --operation %q, must be install or scan", operation)
+		}
+
+		rebootOption := "NoReboot"
+		if reboot {
+			rebootOption = "RebootIfNeeded"
+		}
+
+		tagFilters, err := ec2_instance.ParseTagFilters(tags)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if filter == "" && len(tagFilters) == 0 && !all {
+			log.Fatal("select instances with --filter or --tag, or target every instance with --all")
+		}
+
+		manager, err := ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+		manager.PageSize = pageSize
+		manager.TagFilters = tagFilters
+
+		err = manager.FetchInstances(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager.Filter(ec2_instance.IsRunningFilter)
+
+		err = manager.FetchSSMDetails()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		manager.Filter(func(instance ec2_instance.EC2Instance) bool {
+			if !instance.IsSSM {
+				log.Printf("skipping %s (%s): not managed by SSM", instance.Name, instance.ID)
+			}
+			return instance.IsSSM
+		})
+		if len(manager.Instances) == 0 {
+			log.Fatal("no SSM managed instances match")
+		}
+
+		sort.Slice(manager.Instances, func(i, j int) bool {
+			return manager.Instances[i].Name < manager.Instances[j].Name
+		})
+
+		err = manager.FetchPatchStates()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		action := fmt.Sprintf("%s with %s on %d instances", patchOperation, rebootOption, len(manager.Instances))
+
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "Dry run, would run %s:\n", action)
+			renderPatchPreview(manager.Instances)
+			return
+		}
+
+		if patchOperation == "Install" {
+			err = confirmProtected("Patching "+strconv.Itoa(len(manager.Instances))+" instances", yes)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		ids := make([]string, len(manager.Instances))
+		for i, instance := range manager.Instances {
+			ids[i] = instance.ID
+		}
+
+		fmt.Fprintf(os.Stderr, "Running %s\n", action)
+
+		results, err := manager.RunCommand(ids, ec2_instance.CommandRequest{
+			Document: "AWS-RunPatchBaseline",
+			Parameters: map[string][]string{
+				"Operation":    {patchOperation},
+				"RebootOption": {rebootOption},
+			},
+			Comment:        "kci ssm update",
+			Timeout:        timeout,
+			MaxConcurrency: maxConcurrency,
+			MaxErrors:      maxErrors,
+			Progress:       progress("finished"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// the patch states now reflect this run
+		err = manager.FetchPatchStates()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		report := output.NewReport("Name", "ID", "Status", "Installed", "Failed", "Missing", "Pending Reboot")
+		data := make([]ssmUpdateResult, len(results))
+		succeeded, failed, pendingReboot := 0, 0, 0
+
+		for i, result := range results {
+			instance := manager.Instances[i]
+			data[i] = ssmUpdateResult{Name: instance.Name, CommandResult: result, Patch: instance.Patch}
+
+			if result.Err != nil {
+				log.Printf("%s (%s): %v", instance.Name, instance.ID, result.Err)
+			}
+			if result.Status == "Success" {
+				succeeded++
+			} else {
+				failed++
+			}
+
+			row := []string{instance.Name, instance.ID, result.Status, "", "", "", ""}
+			if state := instance.Patch; state != nil {
+				row[3] = strconv.Itoa(int(state.Installed))
+				row[4] = strconv.Itoa(int(state.Failed))
+				row[5] = strconv.Itoa(int(state.Missing))
+				row[6] = strconv.Itoa(int(state.InstalledPendingReboot))
+
+				if state.InstalledPendingReboot > 0 {
+					pendingReboot++
+				}
+			}
+
+			report.Append(row)
+		}
+		report.Data = data
+
+		render(report)
+
+		fmt.Fprintf(os.Stderr, "%d succeeded, %d failed, %d need a reboot\n", succeeded, failed, pendingReboot)
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// renderPatchPreview shows the instances a patch run would target with their
+// current patch state.
+func renderPatchPreview(instances []ec2_instance.EC2Instance) {
+	report := output.NewReport("Name", "ID", "Missing", "Critical", "Security", "Pending Reboot", "Last Operation")
+	report.Data = instances
+
+	for _, instance := range instances {
+		state := instance.Patch
+		if state == nil {
+			report.Append([]string{instance.Name, instance.ID, "", "", "", "", "never scanned"})
+			continue
+		}
+
+		report.Append([]string{
+			instance.Name,
+			instance.ID,
+			strconv.Itoa(int(state.Missing)),
+			strconv.Itoa(int(state.CriticalNonCompliant)),
+			strconv.Itoa(int(state.SecurityNonCompliant)),
+			strconv.Itoa(int(state.InstalledPendingReboot)),
+			state.Operation + " " + state.OperationEndTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	render(report)
+}
+
+func init() {
+	ssmCmd.AddCommand(ssmUpdateCmd)
+	ssmUpdateCmd.Flags().StringP("filter", "f", "", "Filter instances by name")
+	ssmUpdateCmd.Flags().StringArrayP("tag", "t", nil, "Filter instances by tag, KEY=VALUE with * and ? wildcards, may be repeated")
+	ssmUpdateCmd.Flags().Bool("all", false, "Patch every SSM managed instance")
+	ssmUpdateCmd.Flags().Int32("page-size", 0, "Number of instances to request per API call (5-1000)")
+	ssmUpdateCmd.Flags().String("operation", "install", "Patch baseline operation, install or scan")
+	ssmUpdateCmd.Flags().Bool("reboot", false, "Reboot instances when an installed patch requires it")
+	ssmUpdateCmd.Flags().String("max-concurrency", "10%", "Maximum number or percentage of all instances patched at once")
+	ssmUpdateCmd.Flags().String("max-errors", "0", "Number or percentage of all instances that may fail before no more are patched")
+	ssmUpdateCmd.Flags().Duration("timeout", time.Hour, "Maximum time to wait for each instance once patching started, it is left to finish after that")
+	ssmUpdateCmd.Flags().Bool("dry-run", false, "List the instances that would be patched without patching")
+	ssmUpdateCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation in protected environments")
+}
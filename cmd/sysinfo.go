@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"strings"
 
+	"github.com/KineticCommerce/kci/environment"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +23,42 @@ var sysinfoCmd = &cobra.Command{
 	},
 }
 
+// statusEnvironments returns the configured environments that have a status
+// URL.
+func statusEnvironments() []environment.Environment {
+	envs := []environment.Environment{}
+	for _, env := range settings.Environments {
+		if env.StatusURL != "" {
+			envs = append(envs, env)
+		}
+	}
+
+	return envs
+}
+
+// fetchStatus fetches a `status` endpoint of an environment and decodes the
+// JSON response into result.
+func fetchStatus(env environment.Environment, endpoint string, result interface{}) error {
+	url := strings.TrimSuffix(env.StatusURL, "/") + "/status/" + endpoint
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Received non-200 response from %s: %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, result)
+}
+
 func init() {
 	rootCmd.AddCommand(sysinfoCmd)
 }
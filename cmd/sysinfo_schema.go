@@ -1,13 +1,9 @@
 package cmd
 
 import (
-	"encoding/json"
-	"io/ioutil"
 	"log"
-	"net/http"
-	"os"
 
-	"github.com/olekukonko/tablewriter"
+	"github.com/KineticCommerce/kci/output"
 	"github.com/spf13/cobra"
 )
 
@@ -29,44 +25,20 @@ var sysinfoSchemaCmd = &cobra.Command{
 	Use:   "schema",
 	Short: "display current sqitch migration for deployed services",
 	Run: func(cmd *cobra.Command, args []string) {
-		// TODO Move to function when used more than once...
+		report := output.NewReport("Env", "Core Change", "Core Planned At", "Kiehls Change", "Kiehls Planned At")
+		results := map[string]SchemaResponse{}
 
-		urls := map[string]string{
-			"dit":     "https://kcs-dev.kineticcommercetech.io/status/sqitch",
-			"staging": "https://kcs-staging.kineticcommercetech.io/status/sqitch",
-			"prod":    "https://kcs.kineticcommerce.io/status/sqitch",
-			"prod-eu": "https://kcs-prod-eu-platform.kineticcommerce.io/status/sqitch",
-		}
-
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Env", "Core Change", "Core Planned At", "Kiehls Change", "Kiehls Planned At"})
-
-		// TODO probably a good idea to move this out of the display loop
 		// TODO with multiple DBs this is not as clean as it could be
-		for key, url := range urls {
-			resp, err := http.Get(url)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				log.Fatalf("Received non-200 response: %d\n", resp.StatusCode)
-			}
-
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				log.Fatal(err)
-			}
-
+		for _, env := range statusEnvironments() {
 			var result SchemaResponse
-			err = json.Unmarshal(body, &result)
+			err := fetchStatus(env, "sqitch", &result)
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			table.Append([]string{
-				key,
+			results[env.DisplayName()] = result
+			report.Append([]string{
+				env.DisplayName(),
 				result.Core.ChangeID,
 				result.Core.PlannedAt,
 				result.KiehlsCAS.ChangeID,
@@ -74,7 +46,8 @@ var sysinfoSchemaCmd = &cobra.Command{
 			})
 		}
 
-		table.Render()
+		report.Data = results
+		render(report)
 	},
 }
 
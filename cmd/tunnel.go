@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/KineticCommerce/kci/database"
+	"github.com/KineticCommerce/kci/ec2_instance"
+	"github.com/KineticCommerce/kci/ssh_jump"
+	"github.com/spf13/cobra"
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel -L [bind_address:]port:target[:port] ...",
+	Short: "Forward local ports to databases and hosts behind the bastion",
+	Long: `Forward local ports to databases and hosts behind the bastion, like ssh -L.
+
+The target may be an RDS instance identifier, an instance Name tag or ID, or
+a host name or IP address as seen from the bastion. Databases and instances
+are looked up first, so a Name tag such as web.prod is found even though it
+looks like a host name. The port may be left out for databases, which default
+to the port they listen on.
+
+  kci tunnel -L 5432:orders-db -L 8080:web-1:80
+
+Forwards run until interrupted with Ctrl-C.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		specs, _ := cmd.Flags().GetStringArray("local")
+		if len(specs) == 0 {
+			log.Fatal("at least one forward (-L) is required")
+		}
+
+		ssh, err := loadSSHSettings(cmd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(ssh.Hops) == 0 {
+			log.Fatal("no jump host configured for the environment, use --jump")
+		}
+
+		resolver := &targetResolver{}
+
+		var forwards []ssh_jump.Forward
+		for _, spec := range specs {
+			fwd, err := ssh_jump.ParseForward(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			fwd, err = resolver.resolve(fwd)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			forwards = append(forwards, fwd)
+		}
+
+		pool, err := ssh_jump.NewPool(ssh.Hops, ssh.HostKeys, ssh.Auth)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer pool.Close()
+
+		err = pool.Connect()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var listeners []net.Listener
+		for _, fwd := range forwards {
+			listener, err := net.Listen("tcp", fwd.LocalAddress())
+			if err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				pool.Close()
+				log.Fatalf("unable to listen on %s: %v", fwd.LocalAddress(), err)
+			}
+
+			listeners = append(listeners, listener)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var wg sync.WaitGroup
+		for i, fwd := range forwards {
+			fmt.Fprintf(os.Stderr, "Forwarding %s to %s\n", listeners[i].Addr(), fwd.RemoteAddress())
+
+			wg.Add(1)
+			go func(listener net.Listener, remote string) {
+				defer wg.Done()
+
+				err := pool.Forward(ctx, listener, remote)
+				if err != nil {
+					log.Print(err)
+				}
+			}(listeners[i], fwd.RemoteAddress())
+		}
+
+		fmt.Fprintln(os.Stderr, "Press Ctrl-C to stop")
+		wg.Wait()
+	},
+}
+
+// targetResolver resolves the targets of forwards through AWS, loading the
+// managers only when a target needs them.
+type targetResolver struct {
+	databases *database.RDSManager
+	instances *ec2_instance.EC2InstanceManager
+}
+
+// resolve replaces a database or instance target by its address. IP
+// addresses are left as they are. Other targets are looked up as a database
+// identifier and then as an instance Name tag or ID, so that dotted names such
+// as web.prod find their instance; a dotted target that matches neither is
+// left as a host name for the far end to resolve.
+func (r *targetResolver) resolve(fwd ssh_jump.Forward) (ssh_jump.Forward, error) {
+	if net.ParseIP(fwd.Host) != nil {
+		if fwd.Port == "" {
+			return fwd, fmt.Errorf("forward to %s needs a port", fwd.Host)
+		}
+		return fwd, nil
+	}
+
+	var err error
+
+	// database identifiers cannot contain dots
+	if !strings.Contains(fwd.Host, ".") {
+		if r.databases == nil {
+			r.databases, err = database.NewManager(awsConfig())
+			if err != nil {
+				return fwd, err
+			}
+		}
+
+		db, err := r.databases.FetchDatabase(fwd.Host)
+		if err == nil {
+			fwd.Host = db.Endpoint
+			if fwd.Port == "" {
+				fwd.Port = strconv.Itoa(int(db.Port))
+			}
+			return fwd, nil
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			return fwd, err
+		}
+	}
+
+	if r.instances == nil {
+		r.instances, err = ec2_instance.NewManager(awsConfig())
+		if err != nil {
+			return fwd, err
+		}
+	}
+
+	instance, err := r.instances.FindInstance(fwd.Host)
+	if errors.Is(err, ec2_instance.ErrInstanceNotFound) {
+		if !strings.Contains(fwd.Host, ".") {
+			return fwd, fmt.Errorf("%s is neither a database nor a running instance", fwd.Host)
+		}
+		if fwd.Port == "" {
+			return fwd, fmt.Errorf("forward to %s needs a port", fwd.Host)
+		}
+		return fwd, nil
+	}
+	if err != nil {
+		return fwd, err
+	}
+
+	if fwd.Port == "" {
+		return fwd, fmt.Errorf("forward to instance %s needs a port", fwd.Host)
+	}
+	fwd.Host = instance.PrivateIP
+
+	return fwd, nil
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.Flags().StringArrayP("local", "L", nil, "Forward [bind_address:]port:target[:port], may be repeated")
+	addSSHFlags(tunnelCmd)
+}
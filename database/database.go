@@ -3,49 +3,70 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
 )
 
+// DatabaseInfo represents an AWS RDS database instance. SnapshotCount is the
+// total number of snapshots, which may be more than the loaded Snapshots when
+// RDSManager.MaxSnapshots is set. Endpoint and Port are where the database
+// listens, reachable from inside the VPC only.
 type DatabaseInfo struct {
 	ID               string         `json:"id"`
 	Name             string         `json:"name"`
+	Engine           string         `json:"engine"`
+	Endpoint         string         `json:"endpoint"`
+	Port             int32          `json:"port"`
+	MasterUsername   string         `json:"master_username"`
 	MultiAZ          bool           `json:"multi_az"`
 	SnapshotsEnabled bool           `json:"snapshots_enabled"`
+	SnapshotCount    int            `json:"snapshot_count"`
 	Snapshots        []SnapshotInfo `json:"snapshots"`
 }
 
 // FetchDatabases connects to an AWS and fetches descriptions of all
-// RDS databases.
+// RDS databases, following every result page.
 func (mgr *RDSManager) Fetch(filter string) error {
 	mgr.Databases = []DatabaseInfo{}
 
-	resp, err := mgr.Client.DescribeDBInstances(context.TODO(), &rds.DescribeDBInstancesInput{})
-	if err != nil {
-		return fmt.Errorf("unable to describe databases: %w", err)
+	paginator := rds.NewDescribeDBInstancesPaginator(mgr.Client, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return fmt.Errorf("unable to describe databases: %w", err)
+		}
+
+		err = mgr.appendDatabases(filter, resp.DBInstances)
+		if err != nil {
+			return err
+		}
 	}
 
-	for _, dbInstance := range resp.DBInstances {
+	return nil
+}
+
+// appendDatabases converts a page of DB instances, loads their snapshots and
+// adds them to the Databases field.
+func (mgr *RDSManager) appendDatabases(filter string, dbInstances []types.DBInstance) error {
+	for _, dbInstance := range dbInstances {
 		if len(filter) > 0 && !strings.Contains(aws.ToString(dbInstance.DBInstanceIdentifier), filter) {
 			continue
 		}
 
-		dbInfo := DatabaseInfo{
-			Name:             aws.ToString(dbInstance.DBName),
-			ID:               aws.ToString(dbInstance.DBInstanceIdentifier),
-			SnapshotsEnabled: aws.ToInt32(dbInstance.BackupRetentionPeriod) > 0,
-			MultiAZ:          *dbInstance.MultiAZ,
-		}
+		dbInfo := newDatabaseInfo(dbInstance)
 
-		snapshots, err := mgr.FetchSnapshots(dbInfo.ID)
+		snapshots, err := mgr.fetchAllSnapshots(dbInfo.ID)
 		if err != nil {
 			return fmt.Errorf("could not load snapshots: %v", err)
 		}
 
-		dbInfo.Snapshots = snapshots
+		dbInfo.SnapshotCount = len(snapshots)
+		dbInfo.Snapshots = mgr.capSnapshots(snapshots)
 
 		mgr.Databases = append(mgr.Databases, dbInfo)
 	}
@@ -53,22 +74,65 @@ func (mgr *RDSManager) Fetch(filter string) error {
 	return nil
 }
 
+// FetchDatabase describes the database with the given identifier, without
+// loading its snapshots.
+func (mgr *RDSManager) FetchDatabase(id string) (DatabaseInfo, error) {
+	resp, err := mgr.Client.DescribeDBInstances(context.TODO(), &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(id),
+	})
+
+	var notFound *types.DBInstanceNotFoundFault
+	if errors.As(err, &notFound) || (err == nil && len(resp.DBInstances) == 0) {
+		return DatabaseInfo{}, fmt.Errorf("database %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return DatabaseInfo{}, fmt.Errorf("unable to describe database %s: %w", id, err)
+	}
+
+	return newDatabaseInfo(resp.DBInstances[0]), nil
+}
+
+// ErrNotFound is returned when a database does not exist.
+var ErrNotFound = errors.New("database not found")
+
+func newDatabaseInfo(dbInstance types.DBInstance) DatabaseInfo {
+	dbInfo := DatabaseInfo{
+		Name:             aws.ToString(dbInstance.DBName),
+		ID:               aws.ToString(dbInstance.DBInstanceIdentifier),
+		Engine:           aws.ToString(dbInstance.Engine),
+		MasterUsername:   aws.ToString(dbInstance.MasterUsername),
+		SnapshotsEnabled: aws.ToInt32(dbInstance.BackupRetentionPeriod) > 0,
+		MultiAZ:          aws.ToBool(dbInstance.MultiAZ),
+	}
+
+	if dbInstance.Endpoint != nil {
+		dbInfo.Endpoint = aws.ToString(dbInstance.Endpoint.Address)
+		dbInfo.Port = aws.ToInt32(dbInstance.Endpoint.Port)
+	}
+
+	return dbInfo
+}
+
+// LatestSnapshot returns the newest snapshot that is no longer being created.
 func (db *DatabaseInfo) LatestSnapshot() (SnapshotInfo, error) {
 	if !db.SnapshotsEnabled {
 		return SnapshotInfo{}, fmt.Errorf("cannot return latest snapshot if snapshots disabled")
 	}
 
-	if len(db.Snapshots) < 0 {
-		return SnapshotInfo{}, fmt.Errorf("cannot return latest snapshot if no snapshots registered")
+	for i := len(db.Snapshots) - 1; i >= 0; i-- {
+		if !db.Snapshots[i].Creating() {
+			return db.Snapshots[i], nil
+		}
 	}
 
-	return db.Snapshots[len(db.Snapshots)-1], nil
+	return SnapshotInfo{}, fmt.Errorf("cannot return latest snapshot if no snapshots registered")
 }
 
 func (db *DatabaseInfo) LatestSnapshotID() string {
-	if db.SnapshotsEnabled && len(db.Snapshots) > 0 {
-		return db.Snapshots[len(db.Snapshots)-1].ID
+	snapshot, err := db.LatestSnapshot()
+	if err != nil {
+		return ""
 	}
 
-	return ""
+	return snapshot.ID
 }
@@ -1,16 +1,18 @@
 package database
 
 import (
-	"context"
-	"fmt"
-
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 )
 
+// RDSManager provides access to a list of RDS databases and their snapshots.
+//
+// MaxSnapshots caps the number of snapshots kept per database. All snapshots are
+// still read so that the newest ones are kept; zero keeps everything.
 type RDSManager struct {
-	Databases []DatabaseInfo
-	Client    *rds.Client
+	Databases    []DatabaseInfo
+	Client       *rds.Client
+	MaxSnapshots int
 }
 
 // NewManagerWithClient creates a new RDSManager with a supplied aws client.
@@ -21,14 +23,8 @@ func NewManagerWithClient(client *rds.Client) *RDSManager {
 	}
 }
 
-// NewManager creates a new RDSManager using the default AWS config and client
-func NewManager() (*RDSManager, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
-	}
-
+// NewManager creates a new RDSManager with a client for the given AWS config.
+func NewManager(cfg aws.Config) (*RDSManager, error) {
 	client := rds.NewFromConfig(cfg)
 
 	mgr := NewManagerWithClient(client)
@@ -10,35 +10,86 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 )
 
+// SnapshotInfo represents a DB snapshot. Created is zero while the snapshot is
+// still being created.
 type SnapshotInfo struct {
 	ID      string    `json:"id"`
 	Size    int32     `json:"size"`
+	Status  string    `json:"status"`
 	Created time.Time `json:"created"`
 }
 
+// Creating reports whether the snapshot has no creation time yet.
+func (snapshot *SnapshotInfo) Creating() bool {
+	return snapshot.Created.IsZero()
+}
+
+// FetchSnapshots returns the snapshots of a database sorted from oldest to
+// newest, with those still being created last. When MaxSnapshots is set, only
+// the newest MaxSnapshots are returned, besides those still being created.
 func (mgr *RDSManager) FetchSnapshots(identifier string) ([]SnapshotInfo, error) {
+	snapshots, err := mgr.fetchAllSnapshots(identifier)
+	if err != nil {
+		return snapshots, err
+	}
+
+	return mgr.capSnapshots(snapshots), nil
+}
+
+// fetchAllSnapshots reads every page of DescribeDBSnapshots for a database. The
+// API does not return snapshots in creation order, so all pages are needed to
+// find the latest one.
+func (mgr *RDSManager) fetchAllSnapshots(identifier string) ([]SnapshotInfo, error) {
 	snapshots := []SnapshotInfo{}
 
-	snapshotsResp, err := mgr.Client.DescribeDBSnapshots(context.TODO(), &rds.DescribeDBSnapshotsInput{
+	paginator := rds.NewDescribeDBSnapshotsPaginator(mgr.Client, &rds.DescribeDBSnapshotsInput{
 		DBInstanceIdentifier: aws.String(identifier),
 	})
 
-	if err != nil {
-		return snapshots, fmt.Errorf("Unable to list DB snapshots for %s: %v", identifier, err)
-	}
+	for paginator.HasMorePages() {
+		snapshotsResp, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return snapshots, fmt.Errorf("Unable to list DB snapshots for %s: %v", identifier, err)
+		}
 
-	for _, snapshot := range snapshotsResp.DBSnapshots {
-		snapshotInfo := SnapshotInfo{
-			ID:      *snapshot.DBSnapshotIdentifier,
-			Created: *snapshot.SnapshotCreateTime,
-			Size:    *snapshot.AllocatedStorage,
+		for _, snapshot := range snapshotsResp.DBSnapshots {
+			snapshots = append(snapshots, SnapshotInfo{
+				ID:      aws.ToString(snapshot.DBSnapshotIdentifier),
+				Size:    aws.ToInt32(snapshot.AllocatedStorage),
+				Status:  aws.ToString(snapshot.Status),
+				Created: aws.ToTime(snapshot.SnapshotCreateTime),
+			})
 		}
-		snapshots = append(snapshots, snapshotInfo)
 	}
 
-	sort.Slice(snapshots, func(i, j int) bool {
-		return snapshots[i].Created.Before(snapshots[j].Created)
-	})
+	sortSnapshots(snapshots)
 
 	return snapshots, nil
 }
+
+// capSnapshots keeps the newest MaxSnapshots of a sorted snapshot list, and
+// the snapshots still being created after them.
+func (mgr *RDSManager) capSnapshots(snapshots []SnapshotInfo) []SnapshotInfo {
+	created := len(snapshots)
+	for created > 0 && snapshots[created-1].Creating() {
+		created--
+	}
+
+	if mgr.MaxSnapshots > 0 && created > mgr.MaxSnapshots {
+		return snapshots[created-mgr.MaxSnapshots:]
+	}
+
+	return snapshots
+}
+
+// sortSnapshots sorts snapshots from oldest to newest. Snapshots still being
+// created are the newest, and go last.
+func sortSnapshots(snapshots []SnapshotInfo) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].Creating() || snapshots[j].Creating() {
+			return !snapshots[i].Creating() && snapshots[j].Creating()
+		}
+
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+func TestSortSnapshots(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		snapshots []SnapshotInfo
+		want      []string
+	}{
+		{
+			name:      "by creation time",
+			snapshots: []SnapshotInfo{{ID: "c", Created: day(3)}, {ID: "a", Created: day(1)}, {ID: "b", Created: day(2)}},
+			want:      []string{"a", "b", "c"},
+		},
+		{
+			name:      "creating last",
+			snapshots: []SnapshotInfo{{ID: "new"}, {ID: "b", Created: day(2)}, {ID: "a", Created: day(1)}},
+			want:      []string{"a", "b", "new"},
+		},
+		{
+			name:      "creating keep their order",
+			snapshots: []SnapshotInfo{{ID: "x"}, {ID: "a", Created: day(1)}, {ID: "y"}},
+			want:      []string{"a", "x", "y"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sortSnapshots(tt.snapshots)
+
+			for i, id := range tt.want {
+				if tt.snapshots[i].ID != id {
+					t.Fatalf("got %v, want %v", tt.snapshots, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLatestSnapshotID(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		db   DatabaseInfo
+		want string
+	}{
+		{
+			name: "disabled",
+			db:   DatabaseInfo{Snapshots: []SnapshotInfo{{ID: "a", Created: created}}},
+			want: "",
+		},
+		{
+			name: "none",
+			db:   DatabaseInfo{SnapshotsEnabled: true},
+			want: "",
+		},
+		{
+			name: "skips creating",
+			db:   DatabaseInfo{SnapshotsEnabled: true, Snapshots: []SnapshotInfo{{ID: "a", Created: created}, {ID: "new"}}},
+			want: "a",
+		},
+		{
+			name: "only creating",
+			db:   DatabaseInfo{SnapshotsEnabled: true, Snapshots: []SnapshotInfo{{ID: "new"}}},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.db.LatestSnapshotID()
+			if got != tt.want {
+				t.Errorf("LatestSnapshotID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchSnapshots(t *testing.T) {
+	// the API returns snapshots in no particular order, the newest on the
+	// first page and the oldest on the last
+	pages := []string{
+		`<DBSnapshot><DBSnapshotIdentifier>d4</DBSnapshotIdentifier><SnapshotCreateTime>2024-01-04T00:00:00Z</SnapshotCreateTime><Status>available</Status></DBSnapshot>
+		 <DBSnapshot><DBSnapshotIdentifier>new</DBSnapshotIdentifier><Status>creating</Status></DBSnapshot>`,
+		`<DBSnapshot><DBSnapshotIdentifier>d2</DBSnapshotIdentifier><SnapshotCreateTime>2024-01-02T00:00:00Z</SnapshotCreateTime><Status>available</Status></DBSnapshot>
+		 <DBSnapshot><DBSnapshotIdentifier>d5</DBSnapshotIdentifier><SnapshotCreateTime>2024-01-05T00:00:00Z</SnapshotCreateTime><Status>available</Status></DBSnapshot>`,
+		`<DBSnapshot><DBSnapshotIdentifier>d1</DBSnapshotIdentifier><SnapshotCreateTime>2024-01-01T00:00:00Z</SnapshotCreateTime><Status>available</Status></DBSnapshot>
+		 <DBSnapshot><DBSnapshotIdentifier>d3</DBSnapshotIdentifier><SnapshotCreateTime>2024-01-03T00:00:00Z</SnapshotCreateTime><Status>available</Status></DBSnapshot>`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		page, _ := strconv.Atoi(r.Form.Get("Marker"))
+		marker := ""
+		if page+1 < len(pages) {
+			marker = "<Marker>" + strconv.Itoa(page+1) + "</Marker>"
+		}
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<DescribeDBSnapshotsResponse><DescribeDBSnapshotsResult><DBSnapshots>%s</DBSnapshots>%s</DescribeDBSnapshotsResult></DescribeDBSnapshotsResponse>`, pages[page], marker)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxSnapshots int
+		want         []string
+		wantLatest   string
+	}{
+		{0, []string{"d1", "d2", "d3", "d4", "d5", "new"}, "d5"},
+		{2, []string{"d4", "d5", "new"}, "d5"},
+		{1, []string{"d5", "new"}, "d5"},
+		{10, []string{"d1", "d2", "d3", "d4", "d5", "new"}, "d5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.maxSnapshots), func(t *testing.T) {
+			mgr := NewManagerWithClient(rds.New(rds.Options{
+				Region:       "us-east-1",
+				Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+				BaseEndpoint: aws.String(server.URL),
+			}))
+			mgr.MaxSnapshots = tt.maxSnapshots
+
+			snapshots, err := mgr.FetchSnapshots("orders-db")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, snapshot := range snapshots {
+				got = append(got, snapshot.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchSnapshots() = %v, want %v", got, tt.want)
+			}
+
+			db := DatabaseInfo{SnapshotsEnabled: true, Snapshots: snapshots}
+			if latest := db.LatestSnapshotID(); latest != tt.wantLatest {
+				t.Errorf("LatestSnapshotID() = %q, want %q", latest, tt.wantLatest)
+			}
+		})
+	}
+}
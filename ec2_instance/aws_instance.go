@@ -3,16 +3,21 @@ package ec2_instance
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
-// EC2Instance represents an AWS ec2 instance.
+// EC2Instance represents an AWS ec2 instance. SSM is only set by
+// FetchSSMDetails, for managed instances, and Patch only by FetchPatchStates.
 type EC2Instance struct {
 	ID              string `json:"id"`
 	Name            string `json:"name"`
@@ -23,20 +28,37 @@ type EC2Instance struct {
 	Status          string `json:"status"`
 	PublicIP        string `json:"public_ip"`
 	PrivateIP       string `json:"private_ip"`
+	SubnetID        string `json:"subnet_id"`
+	VpcID           string `json:"vpc_id"`
+	InstanceProfile string `json:"instance_profile"`
 	OsVersion       string `json:"os_version"`
 	RebootRequired  string `json:"reboot_required"`
 	SecurityUpdates int    `json:"security_updates"`
 	Uptime          string `json:"uptime"`
+
+	SSM   *SSMState   `json:"ssm,omitempty"`
+	Patch *PatchState `json:"patch,omitempty"`
 }
 
 // EC2InstanceManager provides access to a list of EC2 instances. This includes
 // methods to fetch, describe, and such.
+//
+// PageSize sets the number of results requested per DescribeInstances call. Zero
+// leaves it to AWS, otherwise it must be between 5 and 1000. TagFilters limits
+// FetchInstances to instances with the given tag values; a value may contain
+// the * and ? wildcards.
 type EC2InstanceManager struct {
-	Instances []EC2Instance
-	Client    *ec2.Client
+	Instances  []EC2Instance
+	Client     *ec2.Client
+	SSMClient  *ssm.Client
+	IAMClient  *iam.Client
+	PageSize   int32
+	TagFilters map[string]string
 }
 
 // NewManagerWithClient creates a new EC2InstanceManager with a supplied aws client.
+// The SSMClient field must be set separately before fetching SSM details, and
+// the IAMClient field before diagnosing.
 func NewManagerWithClient(client *ec2.Client) *EC2InstanceManager {
 	return &EC2InstanceManager{
 		Instances: []EC2Instance{},
@@ -44,16 +66,14 @@ func NewManagerWithClient(client *ec2.Client) *EC2InstanceManager {
 	}
 }
 
-// NewManager creates a new EC2InstanceManager using the default AWS config and client
-func NewManager() (*EC2InstanceManager, error) {
-	cfg, err := config.LoadDefaultConfig(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("unable to load SDK config: %w", err)
-	}
-
+// NewManager creates a new EC2InstanceManager with EC2, SSM and IAM clients
+// for the given AWS config.
+func NewManager(cfg aws.Config) (*EC2InstanceManager, error) {
 	client := ec2.NewFromConfig(cfg)
 
 	mgr := NewManagerWithClient(client)
+	mgr.SSMClient = ssm.NewFromConfig(cfg)
+	mgr.IAMClient = iam.NewFromConfig(cfg)
 
 	return mgr, nil
 }
@@ -96,12 +116,16 @@ func (mgr *EC2InstanceManager) FetchAMIAge() error {
 }
 
 // FetchInstances connects to an AWS and fetches descriptions of all
-// ec2 instances. These instances will be available in the Instances
-// field.
+// ec2 instances, following every result page. These instances will be
+// available in the Instances field.
 func (mgr *EC2InstanceManager) FetchInstances(filter string) error {
 	// empty in case of multiple runs
 	mgr.Instances = []EC2Instance{}
 
+	if mgr.PageSize != 0 && (mgr.PageSize < 5 || mgr.PageSize > 1000) {
+		return fmt.Errorf("invalid page size %d, must be between 5 and 1000", mgr.PageSize)
+	}
+
 	var filters []types.Filter
 	if filter != "" {
 		filter = "*" + filter + "*"
@@ -112,16 +136,117 @@ func (mgr *EC2InstanceManager) FetchInstances(filter string) error {
 		})
 	}
 
+	for key, value := range mgr.TagFilters {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + key),
+			Values: []string{value},
+		})
+	}
+
 	input := &ec2.DescribeInstancesInput{
 		Filters: filters,
 	}
+	if mgr.PageSize != 0 {
+		input.MaxResults = aws.Int32(mgr.PageSize)
+	}
 
-	resp, err := mgr.Client.DescribeInstances(context.Background(), input)
-	if err != nil {
-		return fmt.Errorf("failed to describe instances: %w", err)
+	paginator := ec2.NewDescribeInstancesPaginator(mgr.Client, input)
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to describe instances: %w", err)
+		}
+
+		mgr.appendReservations(resp.Reservations)
 	}
 
-	for _, reservation := range resp.Reservations {
+	return nil
+}
+
+// instanceIDPattern matches EC2 instance IDs.
+var instanceIDPattern = regexp.MustCompile(`^i-[0-9a-f]{8,17}$`)
+
+// FindInstance returns the running instance with the given ID or exact Name
+// tag. A Name tag that looks like an instance ID is found when no instance
+// has that ID. It is an error when no instance or more than one matches.
+func (mgr *EC2InstanceManager) FindInstance(nameOrID string) (EC2Instance, error) {
+	var found []EC2Instance
+	var err error
+
+	if instanceIDPattern.MatchString(nameOrID) {
+		found, err = mgr.runningInstances(types.Filter{Name: aws.String("instance-id"), Values: []string{nameOrID}})
+		if err != nil {
+			return EC2Instance{}, err
+		}
+	}
+
+	if len(found) == 0 {
+		found, err = mgr.runningInstances(types.Filter{Name: aws.String("tag:Name"), Values: []string{nameOrID}})
+		if err != nil {
+			return EC2Instance{}, err
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return EC2Instance{}, fmt.Errorf("instance %s: %w", nameOrID, ErrInstanceNotFound)
+	case 1:
+		return found[0], nil
+	}
+
+	ids := make([]string, len(found))
+	for i, instance := range found {
+		ids[i] = instance.ID
+	}
+
+	return EC2Instance{}, fmt.Errorf("%d running instances are named %s (%s), use an instance ID", len(ids), nameOrID, strings.Join(ids, ", "))
+}
+
+// runningInstances returns the running instances matching filter.
+func (mgr *EC2InstanceManager) runningInstances(filter types.Filter) ([]EC2Instance, error) {
+	found := NewManagerWithClient(mgr.Client)
+
+	paginator := ec2.NewDescribeInstancesPaginator(mgr.Client, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+			filter,
+		},
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+
+		found.appendReservations(resp.Reservations)
+	}
+
+	return found.Instances, nil
+}
+
+// ErrInstanceNotFound is returned when no running instance matches.
+var ErrInstanceNotFound = errors.New("no running instance found")
+
+// ParseTagFilters parses KEY=VALUE tag filters into TagFilters.
+func ParseTagFilters(tags []string) (map[string]string, error) {
+	filters := map[string]string{}
+
+	for _, tag := range tags {
+		key, value, found := strings.Cut(tag, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q, must be KEY=VALUE", tag)
+		}
+
+		filters[key] = value
+	}
+
+	return filters, nil
+}
+
+// appendReservations converts the instances of a DescribeInstances result page
+// and adds them to the Instances field.
+func (mgr *EC2InstanceManager) appendReservations(reservations []types.Reservation) {
+	for _, reservation := range reservations {
 		for _, instance := range reservation.Instances {
 			var name string
 
@@ -142,12 +267,15 @@ func (mgr *EC2InstanceManager) FetchInstances(filter string) error {
 				Status:      string(instance.State.Name),
 				PublicIP:    aws.ToString(instance.PublicIpAddress),
 				PrivateIP:   aws.ToString(instance.PrivateIpAddress),
+				SubnetID:    aws.ToString(instance.SubnetId),
+				VpcID:       aws.ToString(instance.VpcId),
+			}
+			if instance.IamInstanceProfile != nil {
+				instanceStruct.InstanceProfile = aws.ToString(instance.IamInstanceProfile.Arn)
 			}
 			mgr.Instances = append(mgr.Instances, instanceStruct)
 		}
 	}
-
-	return nil
 }
 
 //manager.Filter(ec2_instance.RunningInstances)
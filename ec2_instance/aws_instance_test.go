@@ -0,0 +1,116 @@
+package ec2_instance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// fakeDescribeInstances is an EC2 endpoint with running instances, keyed by
+// ID, with their Name tag.
+type fakeDescribeInstances struct {
+	instances map[string]string
+	queries   []string
+}
+
+func (f *fakeDescribeInstances) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	// Filter.1 is the instance state, Filter.2 the ID or Name
+	filter, value := r.Form.Get("Filter.2.Name"), r.Form.Get("Filter.2.Value.1")
+	f.queries = append(f.queries, filter+"="+value)
+
+	var items strings.Builder
+	for id, name := range f.instances {
+		if (filter == "instance-id" && id == value) || (filter == "tag:Name" && name == value) {
+			fmt.Fprintf(&items, `<item><instanceId>%s</instanceId><imageId>ami-1</imageId><launchTime>2024-01-01T00:00:00Z</launchTime><instanceState><name>running</name></instanceState><tagSet><item><key>Name</key><value>%s</value></item></tagSet></item>`, id, name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet>%s</instancesSet></item></reservationSet></DescribeInstancesResponse>`, items.String())
+}
+
+func TestFindInstance(t *testing.T) {
+	tests := []struct {
+		name        string
+		nameOrID    string
+		wantID      string
+		wantQueries []string
+		wantErr     error
+	}{
+		{
+			name:        "by id",
+			nameOrID:    "i-0123456789abcdef0",
+			wantID:      "i-0123456789abcdef0",
+			wantQueries: []string{"instance-id=i-0123456789abcdef0"},
+		},
+		{
+			name:        "short id",
+			nameOrID:    "i-12345678",
+			wantID:      "i-12345678",
+			wantQueries: []string{"instance-id=i-12345678"},
+		},
+		{
+			name:        "by name",
+			nameOrID:    "web-1",
+			wantID:      "i-0123456789abcdef0",
+			wantQueries: []string{"tag:Name=web-1"},
+		},
+		{
+			name:        "name starting with i-",
+			nameOrID:    "i-proxy",
+			wantID:      "i-0aaaaaaaaaaaaaaa1",
+			wantQueries: []string{"tag:Name=i-proxy"},
+		},
+		{
+			name:        "name that looks like an id",
+			nameOrID:    "i-deadbeef",
+			wantID:      "i-0bbbbbbbbbbbbbbb2",
+			wantQueries: []string{"instance-id=i-deadbeef", "tag:Name=i-deadbeef"},
+		},
+		{
+			name:        "not found",
+			nameOrID:    "i-0fffffffffffffff9",
+			wantQueries: []string{"instance-id=i-0fffffffffffffff9", "tag:Name=i-0fffffffffffffff9"},
+			wantErr:     ErrInstanceNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDescribeInstances{instances: map[string]string{
+				"i-0123456789abcdef0": "web-1",
+				"i-12345678":          "legacy",
+				"i-0aaaaaaaaaaaaaaa1": "i-proxy",
+				"i-0bbbbbbbbbbbbbbb2": "i-deadbeef",
+			}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			mgr := NewManagerWithClient(ec2.New(ec2.Options{
+				Region:       "us-east-1",
+				Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+				BaseEndpoint: aws.String(server.URL),
+			}))
+
+			instance, err := mgr.FindInstance(tt.nameOrID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FindInstance(%q) error = %v, want %v", tt.nameOrID, err, tt.wantErr)
+			}
+			if instance.ID != tt.wantID {
+				t.Errorf("FindInstance(%q) = %s, want %s", tt.nameOrID, instance.ID, tt.wantID)
+			}
+			if strings.Join(fake.queries, " ") != strings.Join(tt.wantQueries, " ") {
+				t.Errorf("FindInstance(%q) queried %q, want %q", tt.nameOrID, fake.queries, tt.wantQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package ec2_instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// defaultCommandTimeout is how long RunCommand waits for an instance when the
+// request has no Timeout.
+const defaultCommandTimeout = 15 * time.Minute
+
+// commandWaiters is the number of instances whose results are polled at once.
+const commandWaiters = 10
+
+// commandGrace is how much longer than its timeout an instance is waited for,
+// for the agent to report the result.
+const commandGrace = time.Minute
+
+// defaultMaxConcurrency is the number of instances SSM runs a command on at
+// once when MaxConcurrency is not set.
+const defaultMaxConcurrency = 50
+
+// commandPollInterval is how often the status of an invocation is checked.
+var commandPollInterval = 5 * time.Second
+
+// CommandRequest is a shell script or SSM document to run on instances with
+// SSM Run Command.
+//
+// Commands are run in order by AWS-RunShellScript, as root. When Document is
+// set it is run with Parameters instead. Timeout is the time allowed on each
+// instance once the command started there, zero for defaultCommandTimeout.
+// Shell scripts are stopped by SSM after Timeout; documents set their own
+// execution timeouts, and Timeout only limits how long they are waited for.
+// Progress, when set, is called after each instance with the number done so
+// far.
+//
+// MaxConcurrency and MaxErrors limit all instances together, as a number or a
+// percentage of the instances, e.g. "10" or "25%". Empty values keep the AWS
+// defaults: 50 instances at once, and no errors.
+type CommandRequest struct {
+	Commands       []string
+	Document       string
+	Parameters     map[string][]string
+	Comment        string
+	Timeout        time.Duration
+	MaxConcurrency string
+	MaxErrors      string
+	Progress       func(done int, total int)
+}
+
+// CommandResult is the outcome of a command on one instance. Status is the
+// SSM invocation status, e.g. Success, Failed or TimedOut, or Skipped when the
+// command was not sent to the instance. ExitCode is -1 when the command did not
+// run to completion. Err is set when the result could not be collected at all,
+// or when the command was still running, and left to run, after its timeout.
+// Output is truncated by SSM to 24000 characters.
+type CommandResult struct {
+	InstanceID string `json:"instance_id"`
+	CommandID  string `json:"command_id"`
+	Status     string `json:"status"`
+	ExitCode   int32  `json:"exit_code"`
+	Output     string `json:"output"`
+	Error      string `json:"error"`
+	Err        error  `json:"-"`
+}
+
+// commandSkipped is the Status of instances the command was not sent to.
+const commandSkipped = "Skipped"
+
+// failed reports whether the result counts against MaxErrors.
+func (result CommandResult) failed() bool {
+	return result.Err != nil || (result.Status != string(types.CommandInvocationStatusSuccess) && result.Status != string(types.CommandInvocationStatusCancelled))
+}
+
+// RunCommand runs the request on the given instances with SSM Run Command and
+// waits for every instance to finish. Results are in the order of
+// instanceIDs.
+//
+// SendCommand accepts a limited number of instance IDs per call, so larger
+// fleets are sent in batches, one after another, each once the previous one
+// has finished. Every batch gets MaxConcurrency and what is left of MaxErrors,
+// so both hold for the fleet as a whole. Once more than MaxErrors instances
+// have failed, or sending a batch fails, the remaining instances are skipped
+// with an Err.
+//
+// Instances queued behind MaxConcurrency are waited for until they start,
+// and then for Timeout. Commands that are still running after that are not
+// cancelled, so that e.g. a patch install is not cut short; they count as
+// failed and keep running while the next batch starts.
+func (mgr *EC2InstanceManager) RunCommand(instanceIDs []string, req CommandRequest) ([]CommandResult, error) {
+	if mgr.SSMClient == nil {
+		return nil, fmt.Errorf("cannot run commands without an SSM client")
+	}
+
+	total := len(instanceIDs)
+
+	maxConcurrency, err := commandLimit("MaxConcurrency", req.MaxConcurrency, total)
+	if err != nil {
+		return nil, err
+	}
+	if maxConcurrency == 0 {
+		return nil, fmt.Errorf("invalid MaxConcurrency %q, must allow at least one instance", req.MaxConcurrency)
+	}
+
+	maxErrors, err := commandLimit("MaxErrors", req.MaxErrors, total)
+	if err != nil {
+		return nil, err
+	}
+	if maxErrors < 0 {
+		maxErrors = 0
+	}
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+
+	ctx := context.TODO()
+	results := make([]CommandResult, total)
+	failures := 0
+
+	var mu sync.Mutex
+	done := 0
+	finished := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		done++
+		if req.Progress != nil {
+			req.Progress(done, total)
+		}
+	}
+
+	for start := 0; start < total; start += ssmInstanceIDBatch {
+		end := start + ssmInstanceIDBatch
+		if end > total {
+			end = total
+		}
+		batch := instanceIDs[start:end]
+		delivery := deliveryTimeout(len(batch), maxConcurrency, timeout)
+
+		var skipErr error
+		commandID := ""
+
+		if failures > maxErrors {
+			skipErr = fmt.Errorf("not sent, %d instances failed and MaxErrors is %d", failures, maxErrors)
+		} else {
+			commandID, err = mgr.sendCommand(ctx, batch, req, timeout, delivery, maxConcurrency, maxErrors-failures)
+			if err != nil && start == 0 {
+				return nil, err
+			}
+			skipErr = err
+		}
+
+		if skipErr != nil {
+			for i := start; i < total; i++ {
+				results[i] = CommandResult{InstanceID: instanceIDs[i], Status: commandSkipped, ExitCode: -1, Err: skipErr}
+				finished()
+			}
+			break
+		}
+
+		mgr.waitCommand(ctx, commandID, batch, timeout, delivery, results[start:end], finished)
+
+		for _, result := range results[start:end] {
+			if result.failed() {
+				failures++
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// commandLimit converts a MaxConcurrency or MaxErrors value for total
+// instances to a number. Percentages are rounded down, but a percentage above
+// zero allows at least one instance. An empty value returns -1.
+func commandLimit(name string, value string, total int) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+
+	percent := strings.HasSuffix(value, "%")
+
+	n, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || n < 0 || (percent && n > 100) {
+		return 0, fmt.Errorf("invalid %s %q, must be a number or a percentage", name, value)
+	}
+
+	if !percent {
+		return n, nil
+	}
+
+	limit := total * n / 100
+	if limit == 0 && n > 0 {
+		limit = 1
+	}
+
+	return limit, nil
+}
+
+// deliveryTimeout is how long the instances of a batch may wait for the
+// command to start: one timeout for every round of maxConcurrency instances.
+// A negative maxConcurrency is the AWS default.
+func deliveryTimeout(instances int, maxConcurrency int, timeout time.Duration) time.Duration {
+	if maxConcurrency < 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+
+	rounds := (instances + maxConcurrency - 1) / maxConcurrency
+
+	return time.Duration(rounds) * timeout
+}
+
+// executionTimeout returns the executionTimeout parameter of a shell script:
+// whole seconds, rounded up, as SSM rejects 0.
+func executionTimeout(timeout time.Duration) string {
+	seconds := (timeout + time.Second - 1) / time.Second
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.Itoa(int(seconds))
+}
+
+// sendCommand sends the request to a batch of instances and returns the
+// command ID. The command is dropped on instances where it has not started
+// within delivery. A negative maxConcurrency keeps the AWS default.
+func (mgr *EC2InstanceManager) sendCommand(ctx context.Context, instanceIDs []string, req CommandRequest, timeout time.Duration, delivery time.Duration, maxConcurrency int, maxErrors int) (string, error) {
+	// SSM takes between 30 seconds and 30 days
+	deliverySeconds := int32(30)
+	switch {
+	case delivery > 30*24*time.Hour:
+		deliverySeconds = 30 * 24 * 60 * 60
+	case delivery > 30*time.Second:
+		deliverySeconds = int32(delivery.Seconds())
+	}
+
+	input := &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  instanceIDs,
+		Parameters: map[string][]string{
+			"commands":         req.Commands,
+			"executionTimeout": {executionTimeout(timeout)},
+		},
+		TimeoutSeconds: aws.Int32(deliverySeconds),
+		MaxErrors:      aws.String(strconv.Itoa(maxErrors)),
+	}
+	if req.Document != "" {
+		input.DocumentName = aws.String(req.Document)
+		input.Parameters = req.Parameters
+	}
+	if req.Comment != "" {
+		input.Comment = aws.String(req.Comment)
+	}
+	if maxConcurrency >= 0 {
+		input.MaxConcurrency = aws.String(strconv.Itoa(maxConcurrency))
+	}
+
+	resp, err := mgr.SSMClient.SendCommand(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("unable to send command: %w", err)
+	}
+
+	return aws.ToString(resp.Command.CommandId), nil
+}
+
+// waitCommand collects the results of a command on a batch of instances.
+func (mgr *EC2InstanceManager) waitCommand(ctx context.Context, commandID string, instanceIDs []string, timeout time.Duration, delivery time.Duration, results []CommandResult, finished func()) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < commandWaiters; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// each waiter only touches the result it was handed
+			for i := range jobs {
+				results[i] = mgr.commandResult(ctx, commandID, instanceIDs[i], timeout, delivery)
+				finished()
+			}
+		}()
+	}
+
+	for i := range instanceIDs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// commandResult waits for the command to finish on an instance and collects
+// its output. While the invocation is pending or delayed it is waited for up
+// to delivery, after which SSM drops it; once it runs, the timeout counts
+// from when it started. A command still running after that is left to run.
+func (mgr *EC2InstanceManager) commandResult(ctx context.Context, commandID string, instanceID string, timeout time.Duration, delivery time.Duration) CommandResult {
+	result := CommandResult{InstanceID: instanceID, CommandID: commandID, ExitCode: -1}
+
+	input := &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandID),
+		InstanceId: aws.String(instanceID),
+	}
+
+	sent := time.Now()
+	var started time.Time
+
+	for {
+		resp, err := mgr.SSMClient.GetCommandInvocation(ctx, input)
+
+		var notYet *types.InvocationDoesNotExist
+		switch {
+		case errors.As(err, &notYet):
+			// the invocation shows up shortly after the command is sent
+		case err != nil:
+			result.Err = fmt.Errorf("unable to get command result: %w", err)
+			return result
+		default:
+			result.Status = string(resp.Status)
+			result.ExitCode = resp.ResponseCode
+			result.Output = aws.ToString(resp.StandardOutputContent)
+			result.Error = aws.ToString(resp.StandardErrorContent)
+
+			if commandFinished(resp.Status) {
+				return result
+			}
+			if started.IsZero() {
+				started = executionStart(resp)
+			}
+		}
+
+		switch {
+		case !started.IsZero() && time.Since(started) > timeout+commandGrace:
+			result.Err = fmt.Errorf("still running after %s, left running", timeout)
+			return result
+		case started.IsZero() && time.Since(sent) > delivery+commandGrace:
+			result.Err = fmt.Errorf("not started after %s", delivery)
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Err = fmt.Errorf("command did not finish: %w", ctx.Err())
+			return result
+		case <-time.After(commandPollInterval):
+		}
+	}
+}
+
+// executionStart returns when an invocation started running, or zero while it
+// waits to start.
+func executionStart(resp *ssm.GetCommandInvocationOutput) time.Time {
+	switch resp.Status {
+	case types.CommandInvocationStatusPending, types.CommandInvocationStatusDelayed:
+		return time.Time{}
+	}
+
+	started, err := time.Parse(time.RFC3339, aws.ToString(resp.ExecutionStartDateTime))
+	if err != nil {
+		// running, but the start is not reported yet
+		return time.Now()
+	}
+
+	return started
+}
+
+// commandFinished reports whether an invocation status is final.
+func commandFinished(status types.CommandInvocationStatus) bool {
+	switch status {
+	case types.CommandInvocationStatusSuccess,
+		types.CommandInvocationStatusFailed,
+		types.CommandInvocationStatusTimedOut,
+		types.CommandInvocationStatusCancelled:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,358 @@
+package ec2_instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// fakeRunCommand is an SSM endpoint that runs commands instantly and fails
+// them on the instances in failing.
+type fakeRunCommand struct {
+	failing map[string]bool
+
+	mu    sync.Mutex
+	sends []ssm.SendCommandInput
+}
+
+func (f *fakeRunCommand) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	switch target := r.Header.Get("X-Amz-Target"); target {
+	case "AmazonSSM.SendCommand":
+		input := ssm.SendCommandInput{}
+		for _, id := range body["InstanceIds"].([]interface{}) {
+			input.InstanceIds = append(input.InstanceIds, id.(string))
+		}
+		if value, ok := body["MaxConcurrency"].(string); ok {
+			input.MaxConcurrency = aws.String(value)
+		}
+		if value, ok := body["MaxErrors"].(string); ok {
+			input.MaxErrors = aws.String(value)
+		}
+		if value, ok := body["TimeoutSeconds"].(float64); ok {
+			input.TimeoutSeconds = aws.Int32(int32(value))
+		}
+
+		f.mu.Lock()
+		f.sends = append(f.sends, input)
+		id := len(f.sends)
+		f.mu.Unlock()
+
+		fmt.Fprintf(w, `{"Command":{"CommandId":"cmd-%d"}}`, id)
+	case "AmazonSSM.GetCommandInvocation":
+		instanceID := body["InstanceId"].(string)
+		status, code := "Success", 0
+		if f.failing[instanceID] {
+			status, code = "Failed", 1
+		}
+
+		fmt.Fprintf(w, `{"CommandId":%q,"InstanceId":%q,"Status":%q,"ResponseCode":%d}`, body["CommandId"], instanceID, status, code)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"InvalidAction","message":"unexpected %s"}`, target)
+	}
+}
+
+func TestRunCommandLimitsAcrossBatches(t *testing.T) {
+	ids := make([]string, 120)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("i-%03d", i)
+	}
+
+	tests := []struct {
+		name           string
+		maxConcurrency string
+		maxErrors      string
+		failing        []string
+		// MaxConcurrency and MaxErrors of each batch sent, "" when unset
+		wantSends [][2]string
+		wantSkip  int
+	}{
+		{
+			name:      "errors of earlier batches count",
+			maxErrors: "1",
+			failing:   []string{"i-005", "i-060"},
+			wantSends: [][2]string{{"", "1"}, {"", "0"}},
+			wantSkip:  20,
+		},
+		{
+			name:      "budget left for the last batch",
+			maxErrors: "2",
+			failing:   []string{"i-005", "i-060"},
+			wantSends: [][2]string{{"", "2"}, {"", "1"}, {"", "0"}},
+		},
+		{
+			name:      "no errors allowed by default",
+			failing:   []string{"i-049"},
+			wantSends: [][2]string{{"", "0"}},
+			wantSkip:  70,
+		},
+		{
+			name:           "percentages of the whole fleet",
+			maxConcurrency: "10%",
+			maxErrors:      "5%",
+			failing:        []string{"i-001", "i-002", "i-003"},
+			wantSends:      [][2]string{{"12", "6"}, {"12", "3"}, {"12", "3"}},
+		},
+		{
+			name:      "all instances may fail",
+			maxErrors: "100%",
+			failing:   ids,
+			wantSends: [][2]string{{"", "120"}, {"", "70"}, {"", "20"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunCommand{failing: map[string]bool{}}
+			for _, id := range tt.failing {
+				fake.failing[id] = true
+			}
+
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			mgr := &EC2InstanceManager{
+				SSMClient: ssm.New(ssm.Options{
+					Region:       "us-east-1",
+					Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+					BaseEndpoint: aws.String(server.URL),
+				}),
+			}
+
+			results, err := mgr.RunCommand(ids, CommandRequest{
+				Commands:       []string{"true"},
+				MaxConcurrency: tt.maxConcurrency,
+				MaxErrors:      tt.maxErrors,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(fake.sends) != len(tt.wantSends) {
+				t.Fatalf("sent %d batches, want %d", len(fake.sends), len(tt.wantSends))
+			}
+			for i, send := range fake.sends {
+				got := [2]string{aws.ToString(send.MaxConcurrency), aws.ToString(send.MaxErrors)}
+				if got != tt.wantSends[i] {
+					t.Errorf("batch %d sent MaxConcurrency and MaxErrors %q, want %q", i, got, tt.wantSends[i])
+				}
+				if len(send.InstanceIds) > ssmInstanceIDBatch {
+					t.Errorf("batch %d has %d instances", i, len(send.InstanceIds))
+				}
+				if aws.ToInt32(send.TimeoutSeconds) < 30 {
+					t.Errorf("batch %d sent TimeoutSeconds %d", i, aws.ToInt32(send.TimeoutSeconds))
+				}
+			}
+
+			if len(results) != len(ids) {
+				t.Fatalf("got %d results, want %d", len(results), len(ids))
+			}
+
+			skipped := 0
+			for i, result := range results {
+				if result.InstanceID != ids[i] {
+					t.Errorf("result %d is for %s, want %s", i, result.InstanceID, ids[i])
+				}
+
+				want := "Success"
+				switch {
+				case result.Status == commandSkipped:
+					skipped++
+					if result.Err == nil {
+						t.Errorf("skipped %s has no error", result.InstanceID)
+					}
+					continue
+				case fake.failing[result.InstanceID]:
+					want = "Failed"
+				}
+				if result.Status != want {
+					t.Errorf("%s status %s, want %s", result.InstanceID, result.Status, want)
+				}
+				if !strings.HasPrefix(result.CommandID, "cmd-") {
+					t.Errorf("%s has command ID %q", result.InstanceID, result.CommandID)
+				}
+			}
+			if skipped != tt.wantSkip {
+				t.Errorf("skipped %d instances, want %d", skipped, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestCommandLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		total   int
+		want    int
+		wantErr bool
+	}{
+		{"", 10, -1, false},
+		{"0", 10, 0, false},
+		{"7", 10, 7, false},
+		{"7", 3, 7, false},
+		{"25%", 120, 30, false},
+		{"10%", 5, 1, false},
+		{"0%", 5, 0, false},
+		{"100%", 120, 120, false},
+		{"101%", 120, 0, true},
+		{"-1", 10, 0, true},
+		{"ten", 10, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := commandLimit("MaxErrors", tt.value, tt.total)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("commandLimit(%q, %d) error = %v, want error %v", tt.value, tt.total, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("commandLimit(%q, %d) = %d, want %d", tt.value, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandResult(t *testing.T) {
+	defer func(interval time.Duration) { commandPollInterval = interval }(commandPollInterval)
+	commandPollInterval = time.Millisecond
+
+	invocation := func(status string, started time.Time) string {
+		start := ""
+		if !started.IsZero() {
+			start = started.UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+		return fmt.Sprintf(`{"Status":%q,"ResponseCode":-1,"ExecutionStartDateTime":%q}`, status, start)
+	}
+	notYet := `{"__type":"InvocationDoesNotExist","message":"not yet"}`
+	success := `{"Status":"Success","ResponseCode":0,"StandardOutputContent":"done"}`
+	longAgo := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		// the responses to each GetCommandInvocation, the last one repeats
+		responses  []string
+		timeout    time.Duration
+		wantStatus string
+		wantErr    bool
+	}{
+		{
+			name:       "queued longer than the timeout",
+			responses:  []string{notYet, invocation("Pending", time.Time{}), invocation("Delayed", time.Time{}), invocation("Pending", time.Time{}), success},
+			timeout:    time.Nanosecond,
+			wantStatus: "Success",
+		},
+		{
+			name:       "running within the timeout",
+			responses:  []string{invocation("InProgress", time.Now()), invocation("InProgress", time.Now()), success},
+			timeout:    time.Hour,
+			wantStatus: "Success",
+		},
+		{
+			name:       "running past the timeout",
+			responses:  []string{invocation("Pending", time.Time{}), invocation("InProgress", longAgo)},
+			timeout:    time.Minute,
+			wantStatus: "InProgress",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+				if target := r.Header.Get("X-Amz-Target"); target != "AmazonSSM.GetCommandInvocation" {
+					t.Errorf("unexpected %s", target)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				response := tt.responses[len(tt.responses)-1]
+				if calls < len(tt.responses) {
+					response = tt.responses[calls]
+				}
+				calls++
+
+				if strings.Contains(response, "__type") {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+				fmt.Fprint(w, response)
+			}))
+			defer server.Close()
+
+			mgr := &EC2InstanceManager{
+				SSMClient: ssm.New(ssm.Options{
+					Region:       "us-east-1",
+					Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+					BaseEndpoint: aws.String(server.URL),
+				}),
+			}
+
+			result := mgr.commandResult(context.Background(), "cmd-1", "i-001", tt.timeout, time.Hour)
+			if result.Status != tt.wantStatus {
+				t.Errorf("status %q, want %q", result.Status, tt.wantStatus)
+			}
+			if (result.Err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", result.Err, tt.wantErr)
+			}
+			if calls < len(tt.responses) {
+				t.Errorf("stopped after %d of %d responses", calls, len(tt.responses))
+			}
+		})
+	}
+}
+
+func TestDeliveryTimeout(t *testing.T) {
+	tests := []struct {
+		instances      int
+		maxConcurrency int
+		want           time.Duration
+	}{
+		{50, -1, time.Hour},
+		{51, 50, 2 * time.Hour},
+		{50, 5, 10 * time.Hour},
+		{12, 10, 2 * time.Hour},
+		{1, 1, time.Hour},
+	}
+
+	for _, tt := range tests {
+		got := deliveryTimeout(tt.instances, tt.maxConcurrency, time.Hour)
+		if got != tt.want {
+			t.Errorf("deliveryTimeout(%d, %d) = %s, want %s", tt.instances, tt.maxConcurrency, got, tt.want)
+		}
+	}
+}
+
+func TestExecutionTimeout(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	}
+
+	for _, tt := range tests {
+		if got := executionTimeout(tt.timeout); got != tt.want {
+			t.Errorf("executionTimeout(%s) = %q, want %q", tt.timeout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package ec2_instance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// corePolicies are the managed policies that give the SSM Agent the
+// permissions it needs. AmazonEC2RoleforSSM is deprecated but still works.
+var corePolicies = []string{"AmazonSSMManagedInstanceCore", "AmazonEC2RoleforSSM"}
+
+// ssmEndpointServices are the interface endpoints the SSM Agent needs in a VPC
+// without internet access.
+var ssmEndpointServices = []string{"ssm", "ssmmessages", "ec2messages"}
+
+// defaultHostManagementSetting holds the IAM role of Default Host Management
+// Configuration, which manages instances without an instance profile.
+const defaultHostManagementSetting = "/ssm/managed-instance/default-ec2-instance-management-role"
+
+// Diagnosis is why an instance is, or is not, managed by SSM. PingStatus is
+// empty when the instance never registered with SSM. CorePolicy reports
+// whether a role of the instance profile has AmazonSSMManagedInstanceCore
+// attached. Network is how the instance reaches SSM: through the VPC
+// endpoints, the target of the subnet's default route, or "none". Reasons
+// lists what needs fixing and is empty for an instance that is online.
+//
+// Security groups, network ACLs and custom IAM policies are not checked.
+type Diagnosis struct {
+	InstanceID      string   `json:"instance_id"`
+	Name            string   `json:"name"`
+	PingStatus      string   `json:"ping_status"`
+	InstanceProfile string   `json:"instance_profile"`
+	Roles           []string `json:"roles"`
+	CorePolicy      bool     `json:"core_policy"`
+	Network         string   `json:"network"`
+	Reasons         []string `json:"reasons"`
+}
+
+// Healthy reports whether nothing needs fixing.
+func (d *Diagnosis) Healthy() bool {
+	return len(d.Reasons) == 0
+}
+
+// profileCheck is what is known about an instance profile.
+type profileCheck struct {
+	roles      []string
+	corePolicy bool
+	err        error
+}
+
+// networkCheck is what is known about the route of a subnet, and the
+// endpoints of its VPC.
+type networkCheck struct {
+	egress           string
+	missingEndpoints []string
+	err              error
+}
+
+// Diagnose checks why the instances in the Instances field are or are not
+// managed by SSM: their instance profile and its role, the route out of their
+// subnet and the SSM endpoints of their VPC, and whether they ever
+// registered. Every profile, subnet and VPC is looked up once. Checks that
+// fail, e.g. for lack of IAM permissions, are reported as reasons.
+func (mgr *EC2InstanceManager) Diagnose() ([]Diagnosis, error) {
+	if mgr.IAMClient == nil {
+		return nil, fmt.Errorf("cannot diagnose instances without an IAM client")
+	}
+
+	err := mgr.FetchSSMDetails()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.TODO()
+	defaultHostManagement := mgr.defaultHostManagement(ctx)
+
+	profiles := map[string]*profileCheck{}
+	subnets := map[string]*networkCheck{}
+	vpcs := map[string]*networkCheck{}
+
+	diagnoses := make([]Diagnosis, len(mgr.Instances))
+
+	for i, instance := range mgr.Instances {
+		var profile *profileCheck
+		if instance.InstanceProfile != "" {
+			var ok bool
+			profile, ok = profiles[instance.InstanceProfile]
+			if !ok {
+				profile = mgr.checkProfile(ctx, profileName(instance.InstanceProfile))
+				profiles[instance.InstanceProfile] = profile
+			}
+		}
+
+		var route, endpoints *networkCheck
+		if instance.SubnetID != "" {
+			var ok bool
+			route, ok = subnets[instance.SubnetID]
+			if !ok {
+				route = mgr.checkRoute(ctx, instance.SubnetID, instance.VpcID)
+				subnets[instance.SubnetID] = route
+			}
+
+			endpoints, ok = vpcs[instance.VpcID]
+			if !ok {
+				endpoints = mgr.checkEndpoints(ctx, instance.VpcID)
+				vpcs[instance.VpcID] = endpoints
+			}
+		}
+
+		diagnoses[i] = diagnose(instance, defaultHostManagement, profile, route, endpoints)
+	}
+
+	return diagnoses, nil
+}
+
+// diagnose works out the diagnosis of an instance from the checks of its
+// instance profile, and of the route and endpoints of its subnet. The checks
+// are nil when the instance has no profile or subnet.
+func diagnose(instance EC2Instance, defaultHostManagement bool, profile *profileCheck, route *networkCheck, endpoints *networkCheck) Diagnosis {
+	d := Diagnosis{
+		InstanceID:      instance.ID,
+		Name:            instance.Name,
+		InstanceProfile: profileName(instance.InstanceProfile),
+	}
+	if instance.SSM != nil {
+		d.PingStatus = instance.SSM.PingStatus
+	}
+
+	var problems []string
+
+	switch {
+	case profile == nil && !defaultHostManagement:
+		problems = append(problems, "no IAM instance profile, attach one whose role has AmazonSSMManagedInstanceCore")
+	case profile != nil:
+		d.Roles = profile.roles
+		d.CorePolicy = profile.corePolicy
+
+		switch {
+		case profile.err != nil:
+			problems = append(problems, fmt.Sprintf("unable to check instance profile %s: %v", d.InstanceProfile, profile.err))
+		case len(profile.roles) == 0:
+			problems = append(problems, fmt.Sprintf("instance profile %s has no role", d.InstanceProfile))
+		case !profile.corePolicy:
+			problems = append(problems, fmt.Sprintf("role %s lacks AmazonSSMManagedInstanceCore", strings.Join(profile.roles, ", ")))
+		}
+	}
+
+	if route != nil && endpoints != nil {
+		egress := route.egress
+		// an internet gateway only helps instances with a public address
+		if strings.HasPrefix(egress, "igw-") && instance.PublicIP == "" {
+			egress = ""
+		}
+
+		switch {
+		case route.err != nil:
+			problems = append(problems, fmt.Sprintf("unable to check the route of subnet %s: %v", instance.SubnetID, route.err))
+		case endpoints.err != nil:
+			problems = append(problems, fmt.Sprintf("unable to check the endpoints of VPC %s: %v", instance.VpcID, endpoints.err))
+		case len(endpoints.missingEndpoints) == 0:
+			d.Network = "endpoints"
+		case egress != "":
+			d.Network = egress
+		case route.egress != "":
+			d.Network = "none"
+			problems = append(problems, fmt.Sprintf("subnet %s routes to %s but the instance has no public IP, and VPC %s lacks endpoints for %s", instance.SubnetID, route.egress, instance.VpcID, strings.Join(endpoints.missingEndpoints, ", ")))
+		default:
+			d.Network = "none"
+			problems = append(problems, fmt.Sprintf("subnet %s has no route to the internet, and VPC %s lacks endpoints for %s", instance.SubnetID, instance.VpcID, strings.Join(endpoints.missingEndpoints, ", ")))
+		}
+	}
+
+	switch {
+	case instance.SSM == nil:
+		if len(problems) == 0 {
+			problems = append(problems, "never registered although profile and network look fine, check that the SSM Agent is installed and running")
+		}
+		d.Reasons = problems
+	case !instance.SSM.Online():
+		d.Reasons = append([]string{fmt.Sprintf("agent %s since %s", instance.SSM.PingStatus, instance.SSM.LastPing.Format("2006-01-02 15:04:05"))}, problems...)
+	}
+
+	return d
+}
+
+// defaultHostManagement reports whether Default Host Management Configuration
+// is enabled. It is taken as disabled when the setting cannot be read.
+func (mgr *EC2InstanceManager) defaultHostManagement(ctx context.Context) bool {
+	if mgr.SSMClient == nil {
+		return false
+	}
+
+	resp, err := mgr.SSMClient.GetServiceSetting(ctx, &ssm.GetServiceSettingInput{
+		SettingId: aws.String(defaultHostManagementSetting),
+	})
+	if err != nil || resp.ServiceSetting == nil {
+		return false
+	}
+
+	value := aws.ToString(resp.ServiceSetting.SettingValue)
+
+	return value != "" && value != "$None"
+}
+
+// checkProfile looks up the roles of an instance profile and whether one of
+// them has a core SSM policy attached.
+func (mgr *EC2InstanceManager) checkProfile(ctx context.Context, name string) *profileCheck {
+	check := &profileCheck{}
+
+	resp, err := mgr.IAMClient.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	})
+	if err != nil {
+		check.err = err
+		return check
+	}
+
+	for _, role := range resp.InstanceProfile.Roles {
+		roleName := aws.ToString(role.RoleName)
+		check.roles = append(check.roles, roleName)
+
+		paginator := iam.NewListAttachedRolePoliciesPaginator(mgr.IAMClient, &iam.ListAttachedRolePoliciesInput{
+			RoleName: role.RoleName,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				check.err = err
+				return check
+			}
+
+			for _, policy := range page.AttachedPolicies {
+				for _, core := range corePolicies {
+					if aws.ToString(policy.PolicyName) == core {
+						check.corePolicy = true
+					}
+				}
+			}
+		}
+	}
+
+	return check
+}
+
+// checkRoute finds the target of the default route of a subnet, from its own
+// route table or else the main route table of the VPC. The target is empty
+// when there is no active default route.
+func (mgr *EC2InstanceManager) checkRoute(ctx context.Context, subnetID string, vpcID string) *networkCheck {
+	check := &networkCheck{}
+
+	tables, err := mgr.routeTables(ctx, types.Filter{Name: aws.String("association.subnet-id"), Values: []string{subnetID}})
+	if err == nil && len(tables) == 0 {
+		tables, err = mgr.routeTables(ctx,
+			types.Filter{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			types.Filter{Name: aws.String("association.main"), Values: []string{"true"}})
+	}
+	if err != nil {
+		check.err = err
+		return check
+	}
+
+	for _, table := range tables {
+		for _, route := range table.Routes {
+			if route.State != types.RouteStateActive || aws.ToString(route.DestinationCidrBlock) != "0.0.0.0/0" {
+				continue
+			}
+
+			for _, target := range []*string{route.NatGatewayId, route.GatewayId, route.TransitGatewayId, route.NetworkInterfaceId, route.InstanceId, route.VpcPeeringConnectionId} {
+				if aws.ToString(target) != "" {
+					check.egress = aws.ToString(target)
+					return check
+				}
+			}
+		}
+	}
+
+	return check
+}
+
+func (mgr *EC2InstanceManager) routeTables(ctx context.Context, filters ...types.Filter) ([]types.RouteTable, error) {
+	var tables []types.RouteTable
+
+	paginator := ec2.NewDescribeRouteTablesPaginator(mgr.Client, &ec2.DescribeRouteTablesInput{Filters: filters})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		tables = append(tables, page.RouteTables...)
+	}
+
+	return tables, nil
+}
+
+// checkEndpoints finds which SSM interface endpoints a VPC lacks. Endpoints
+// without private DNS are not used by the agent and count as missing.
+func (mgr *EC2InstanceManager) checkEndpoints(ctx context.Context, vpcID string) *networkCheck {
+	check := &networkCheck{}
+
+	region := mgr.Client.Options().Region
+	names := make([]string, len(ssmEndpointServices))
+	for i, service := range ssmEndpointServices {
+		names[i] = "com.amazonaws." + region + "." + service
+	}
+
+	found := map[string]bool{}
+
+	paginator := ec2.NewDescribeVpcEndpointsPaginator(mgr.Client, &ec2.DescribeVpcEndpointsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			{Name: aws.String("service-name"), Values: names},
+			{Name: aws.String("vpc-endpoint-state"), Values: []string{"available"}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			check.err = err
+			return check
+		}
+
+		for _, endpoint := range page.VpcEndpoints {
+			if aws.ToBool(endpoint.PrivateDnsEnabled) {
+				found[aws.ToString(endpoint.ServiceName)] = true
+			}
+		}
+	}
+
+	for i, service := range ssmEndpointServices {
+		if !found[names[i]] {
+			check.missingEndpoints = append(check.missingEndpoints, service)
+		}
+	}
+
+	return check
+}
+
+// profileName returns the name of an instance profile from its ARN, which
+// may include a path.
+func profileName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
@@ -0,0 +1,195 @@
+package ec2_instance
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagnose(t *testing.T) {
+	online := &SSMState{PingStatus: "Online"}
+	lost := &SSMState{PingStatus: "ConnectionLost", LastPing: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	goodProfile := &profileCheck{roles: []string{"web"}, corePolicy: true}
+	endpoints := &networkCheck{}
+	noEndpoints := &networkCheck{missingEndpoints: []string{"ssm", "ssmmessages", "ec2messages"}}
+
+	instance := func(profile string, publicIP string, ssm *SSMState) EC2Instance {
+		return EC2Instance{
+			ID:              "i-1",
+			Name:            "web-1",
+			InstanceProfile: profile,
+			PublicIP:        publicIP,
+			SubnetID:        "subnet-1",
+			VpcID:           "vpc-1",
+			SSM:             ssm,
+		}
+	}
+	profileARN := "arn:aws:iam::123456789012:instance-profile/app/web"
+
+	tests := []struct {
+		name                  string
+		instance              EC2Instance
+		defaultHostManagement bool
+		profile               *profileCheck
+		route                 *networkCheck
+		endpoints             *networkCheck
+		wantNetwork           string
+		wantReasons           []string
+	}{
+		{
+			name:        "online through endpoints",
+			instance:    instance(profileARN, "", online),
+			profile:     goodProfile,
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+		},
+		{
+			name:        "online through a nat gateway",
+			instance:    instance(profileARN, "", online),
+			profile:     goodProfile,
+			route:       &networkCheck{egress: "nat-1"},
+			endpoints:   noEndpoints,
+			wantNetwork: "nat-1",
+		},
+		{
+			name:        "internet gateway with a public address",
+			instance:    instance(profileARN, "54.0.0.1", online),
+			profile:     goodProfile,
+			route:       &networkCheck{egress: "igw-1"},
+			endpoints:   noEndpoints,
+			wantNetwork: "igw-1",
+		},
+		{
+			name:        "internet gateway without a public address",
+			instance:    instance(profileARN, "", nil),
+			profile:     goodProfile,
+			route:       &networkCheck{egress: "igw-1"},
+			endpoints:   noEndpoints,
+			wantNetwork: "none",
+			wantReasons: []string{"subnet subnet-1 routes to igw-1 but the instance has no public IP"},
+		},
+		{
+			name:        "no route and no endpoints",
+			instance:    instance(profileARN, "", nil),
+			profile:     goodProfile,
+			route:       &networkCheck{},
+			endpoints:   noEndpoints,
+			wantNetwork: "none",
+			wantReasons: []string{"subnet subnet-1 has no route to the internet"},
+		},
+		{
+			name:        "no instance profile",
+			instance:    instance("", "", nil),
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+			wantReasons: []string{"no IAM instance profile"},
+		},
+		{
+			name:                  "default host management without a profile",
+			instance:              instance("", "", online),
+			defaultHostManagement: true,
+			route:                 &networkCheck{},
+			endpoints:             endpoints,
+			wantNetwork:           "endpoints",
+		},
+		{
+			name:        "profile without a role",
+			instance:    instance(profileARN, "", nil),
+			profile:     &profileCheck{},
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+			wantReasons: []string{"instance profile web has no role"},
+		},
+		{
+			name:        "role without the core policy",
+			instance:    instance(profileARN, "", nil),
+			profile:     &profileCheck{roles: []string{"web", "logs"}},
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+			wantReasons: []string{"role web, logs lacks AmazonSSMManagedInstanceCore"},
+		},
+		{
+			name:        "checks that fail",
+			instance:    instance(profileARN, "", nil),
+			profile:     &profileCheck{err: errors.New("access denied")},
+			route:       &networkCheck{err: errors.New("throttled")},
+			endpoints:   endpoints,
+			wantReasons: []string{"unable to check instance profile web: access denied", "unable to check the route of subnet subnet-1: throttled"},
+		},
+		{
+			name:        "never registered",
+			instance:    instance(profileARN, "", nil),
+			profile:     goodProfile,
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+			wantReasons: []string{"never registered although profile and network look fine"},
+		},
+		{
+			name:        "connection lost comes first",
+			instance:    instance(profileARN, "", lost),
+			profile:     &profileCheck{roles: []string{"web"}},
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+			wantReasons: []string{"agent ConnectionLost since 2024-03-01 12:00:00", "role web lacks AmazonSSMManagedInstanceCore"},
+		},
+		{
+			name:        "online with problems",
+			instance:    instance(profileARN, "", online),
+			profile:     &profileCheck{roles: []string{"web"}},
+			route:       &networkCheck{},
+			endpoints:   endpoints,
+			wantNetwork: "endpoints",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := diagnose(tt.instance, tt.defaultHostManagement, tt.profile, tt.route, tt.endpoints)
+
+			if d.Network != tt.wantNetwork {
+				t.Errorf("Network = %q, want %q", d.Network, tt.wantNetwork)
+			}
+			if d.Healthy() != (len(tt.wantReasons) == 0) {
+				t.Errorf("Healthy() = %v with reasons %q", d.Healthy(), d.Reasons)
+			}
+			if len(d.Reasons) != len(tt.wantReasons) {
+				t.Fatalf("Reasons = %q, want %q", d.Reasons, tt.wantReasons)
+			}
+			for i, want := range tt.wantReasons {
+				if !strings.HasPrefix(d.Reasons[i], want) {
+					t.Errorf("Reasons[%d] = %q, want it to start with %q", i, d.Reasons[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiagnoseDetails(t *testing.T) {
+	d := diagnose(EC2Instance{
+		ID:              "i-1",
+		Name:            "web-1",
+		InstanceProfile: "arn:aws:iam::123456789012:instance-profile/app/web",
+		SSM:             &SSMState{PingStatus: "Online"},
+	}, false, &profileCheck{roles: []string{"web"}, corePolicy: true}, nil, nil)
+
+	want := Diagnosis{
+		InstanceID:      "i-1",
+		Name:            "web-1",
+		PingStatus:      "Online",
+		InstanceProfile: "web",
+		Roles:           []string{"web"},
+		CorePolicy:      true,
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("diagnose() = %+v, want %+v", d, want)
+	}
+}
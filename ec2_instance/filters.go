@@ -39,3 +39,15 @@ func (mgr *EC2InstanceManager) Filter(filterFunc FilterFunc) {
 
 	mgr.Instances = mgr.Instances[:j]
 }
+
+// IsConnectionLostFilter matches SSM managed instances whose agent has stopped
+// checking in. FetchSSMDetails must have been called.
+func IsConnectionLostFilter(instance EC2Instance) bool {
+	return instance.SSM != nil && instance.SSM.ConnectionLost()
+}
+
+// IsAgentOutdatedFilter matches SSM managed instances running an outdated SSM
+// Agent. FetchSSMDetails must have been called.
+func IsAgentOutdatedFilter(instance EC2Instance) bool {
+	return instance.SSM != nil && instance.SSM.AgentOutdated()
+}
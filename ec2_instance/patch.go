@@ -0,0 +1,100 @@
+package ec2_instance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// PatchState is the patch compliance of an instance as last reported by SSM
+// Patch Manager. Operation is Scan or Install, and the counts are of patches
+// in the instance's patch baseline.
+type PatchState struct {
+	BaselineID             string    `json:"baseline_id"`
+	PatchGroup             string    `json:"patch_group"`
+	Operation              string    `json:"operation"`
+	OperationEndTime       time.Time `json:"operation_end_time"`
+	RebootOption           string    `json:"reboot_option"`
+	Installed              int32     `json:"installed"`
+	InstalledPendingReboot int32     `json:"installed_pending_reboot"`
+	Missing                int32     `json:"missing"`
+	Failed                 int32     `json:"failed"`
+	CriticalNonCompliant   int32     `json:"critical_non_compliant"`
+	SecurityNonCompliant   int32     `json:"security_non_compliant"`
+	OtherNonCompliant      int32     `json:"other_non_compliant"`
+}
+
+// Compliant reports whether nothing is missing, failed or waiting for a
+// reboot.
+func (state *PatchState) Compliant() bool {
+	return state.Missing == 0 && state.Failed == 0 && state.InstalledPendingReboot == 0
+}
+
+// FetchPatchStates looks up the patch state of the instances in the Instances
+// field and sets their Patch field. Instances that Patch Manager has never
+// scanned are left with a nil Patch.
+func (mgr *EC2InstanceManager) FetchPatchStates() error {
+	if len(mgr.Instances) == 0 {
+		return nil
+	}
+
+	if mgr.SSMClient == nil {
+		return fmt.Errorf("cannot describe patch states without an SSM client")
+	}
+
+	ctx := context.TODO()
+	states := map[string]*PatchState{}
+
+	for start := 0; start < len(mgr.Instances); start += ssmInstanceIDBatch {
+		end := start + ssmInstanceIDBatch
+		if end > len(mgr.Instances) {
+			end = len(mgr.Instances)
+		}
+
+		ids := make([]string, 0, end-start)
+		for _, instance := range mgr.Instances[start:end] {
+			ids = append(ids, instance.ID)
+		}
+
+		paginator := ssm.NewDescribeInstancePatchStatesPaginator(mgr.SSMClient, &ssm.DescribeInstancePatchStatesInput{
+			InstanceIds: ids,
+		})
+		for paginator.HasMorePages() {
+			resp, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("cannot describe instance patch states: %w", err)
+			}
+
+			for _, state := range resp.InstancePatchStates {
+				states[aws.ToString(state.InstanceId)] = newPatchState(state)
+			}
+		}
+	}
+
+	for i := range mgr.Instances {
+		mgr.Instances[i].Patch = states[mgr.Instances[i].ID]
+	}
+
+	return nil
+}
+
+func newPatchState(state types.InstancePatchState) *PatchState {
+	return &PatchState{
+		BaselineID:             aws.ToString(state.BaselineId),
+		PatchGroup:             aws.ToString(state.PatchGroup),
+		Operation:              string(state.Operation),
+		OperationEndTime:       aws.ToTime(state.OperationEndTime),
+		RebootOption:           string(state.RebootOption),
+		Installed:              state.InstalledCount,
+		InstalledPendingReboot: aws.ToInt32(state.InstalledPendingRebootCount),
+		Missing:                state.MissingCount,
+		Failed:                 state.FailedCount,
+		CriticalNonCompliant:   aws.ToInt32(state.CriticalNonCompliantCount),
+		SecurityNonCompliant:   aws.ToInt32(state.SecurityNonCompliantCount),
+		OtherNonCompliant:      aws.ToInt32(state.OtherNonCompliantCount),
+	}
+}
@@ -1,70 +1,196 @@
 package ec2_instance
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/KineticCommerce/kci/ssh_jump"
 	"golang.org/x/crypto/ssh"
 )
 
-// Scan recursively calls Scan on all Instance items. Connection is through a jump
-// bastion which needs the connection info, jump and user.
+// ScanOptions controls a JumpScan over many instances.
 //
-// TODO this could easily be handles as a goroutine to speed things up
-func (mgr *EC2InstanceManager) JumpScan(host string, user string) error {
+// Jump is the chain of bastions to pass through and TargetUser is the user to
+// log in as on the instances; empty values fall back to ~/.ssh/config. HostKeys
+// verifies the host keys of the bastion and the instances, and Auth provides
+// the credentials; both are shared by all workers.
+//
+// Concurrency is the number of instances scanned at once (at least one) and
+// Timeout is the deadline for each instance, zero for none. Progress, when set,
+// is called after each instance with the number of instances done so far.
+type ScanOptions struct {
+	Jump        []ssh_jump.Hop
+	TargetUser  string
+	HostKeys    *ssh_jump.HostKeys
+	Auth        *ssh_jump.Auth
+	Concurrency int
+	Timeout     time.Duration
+	Progress    func(done int, total int)
+
+	pool *ssh_jump.Pool
+}
+
+// Scan recursively calls Scan on all Instance items using a pool of workers.
+// Connection is through a jump bastion, which is connected to once and shared
+// by all workers. Instances keep their order, and an instance that cannot be
+// scanned is logged and marked "No Connection" without stopping the others.
+func (mgr *EC2InstanceManager) JumpScan(opts ScanOptions) error {
+	pool, err := ssh_jump.NewPool(opts.Jump, opts.HostKeys, opts.Auth)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	opts.pool = pool
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	total := len(mgr.Instances)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// each worker only touches the instance it was handed
+			for i := range jobs {
+				err := mgr.Instances[i].jumpScanWithTimeout(opts)
+				if err != nil {
+					// We want to continue here...
+					log.Printf("target scan failed (%s): %v", mgr.Instances[i].Name, err)
+					mgr.Instances[i].Status = "No Connection"
+				}
+
+				mu.Lock()
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, total)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
 	for i := range mgr.Instances {
-		err := mgr.Instances[i].JumpScan(host, user)
-		if err != nil {
-			// We want to continue here...
-			log.Printf("target scan failed (%s): %v", mgr.Instances[i].Name, err)
-			mgr.Instances[i].Status = "No Connection"
-		}
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
 
 	return nil
 }
 
+// jumpScanWithTimeout runs JumpScan on a copy of the instance so that a scan
+// that misses the deadline cannot modify the instance afterwards. The
+// connection of a scan that misses the deadline is closed rather than left
+// open until the pool is.
+func (instance *EC2Instance) jumpScanWithTimeout(opts ScanOptions) error {
+	if opts.Timeout <= 0 {
+		return instance.JumpScan(opts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	scanned := *instance
+	result := make(chan error, 1)
+	go func() {
+		result <- scanned.jumpScan(ctx, opts)
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return err
+		}
+		*instance = scanned
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", opts.Timeout)
+	}
+}
+
 // JumpScan connects to the underlying instance through a jump host (bastion) and
 // runs a Scan.
-func (instance *EC2Instance) JumpScan(host string, user string) error {
-	jump := ssh_jump.New(host, user, instance.PrivateIP, "ubuntu")
+func (instance *EC2Instance) JumpScan(opts ScanOptions) error {
+	return instance.jumpScan(context.Background(), opts)
+}
 
-	err := jump.Connect()
+// jumpScan is JumpScan, closing the connection to the instance once ctx is
+// done.
+func (instance *EC2Instance) jumpScan(ctx context.Context, opts ScanOptions) error {
+	pool := opts.pool
+	if pool == nil {
+		var err error
+		pool, err = ssh_jump.NewPool(opts.Jump, opts.HostKeys, opts.Auth)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+	}
+
+	client, err := pool.Dial(ssh_jump.Hop{Host: instance.PrivateIP, User: opts.TargetUser})
 	if err != nil {
 		return fmt.Errorf("could not perform JumpScan: %w", err)
 	}
-	defer jump.Close()
+	defer client.Close()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-finished:
+		}
+	}()
 
-	return instance.Scan(jump.Client)
+	return instance.Scan(client)
+}
+
+// scanCommands are the checks run by a scan, in the order parseScan expects
+// their output.
+var scanCommands = []string{
+	`lsb_release -d | cut -f2 | awk '{print $2}'`,
+	`uptime | awk '{print $3 " "  $4}' | tr -d ','`,
+	"if [ -f /var/run/reboot-required ]; then echo 'reboot required'; else echo 'no'; fi",
+	"sudo cat /var/lib/update-notifier/updates-available | grep 'security updates' | cut -d' ' -f1",
 }
 
 // Scan runs a scan over an SSH connection. This will be less important when SSM
 // is fully implemented.
 func (instance *EC2Instance) Scan(client *ssh.Client) error {
-	commands := []string{
-		`lsb_release -d | cut -f2 | awk '{print $2}'`,
-		`uptime | awk '{print $3 " "  $4}' | tr -d ','`,
-		"if [ -f /var/run/reboot-required ]; then echo 'reboot required'; else echo 'no'; fi",
-		"sudo cat /var/lib/update-notifier/updates-available | grep 'security updates' | cut -d' ' -f1",
-	}
-
-	output, err := ssh_jump.ExecuteSSHCommands(client, commands)
+	output, err := ssh_jump.ExecuteSSHCommands(client, scanCommands)
 	if err != nil {
 		return fmt.Errorf("Error running commands: %w", err)
 	}
 
+	instance.parseScan(output)
+
+	return nil
+}
+
+// parseScan sets the scanned fields from the output of scanCommands.
+func (instance *EC2Instance) parseScan(output []string) {
 	instance.OsVersion = strings.ReplaceAll(output[0], "\n", "")
 	instance.Uptime = strings.ReplaceAll(output[1], "\n", "")
 	instance.RebootRequired = strings.ReplaceAll(output[2], "\n", "")
 
-	updates, err := strconv.Atoi(output[3])
+	updates, err := strconv.Atoi(strings.TrimSpace(output[3]))
 	if err != nil {
 		updates = 0 // The error is always an empty string
 	}
 	instance.SecurityUpdates = updates
-
-	return nil
 }
@@ -3,34 +3,112 @@ package ec2_instance
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
 )
 
-// Scan through all instances and set the IsSSM flag.
-func (mgr *EC2InstanceManager) FetchSSMDetails() error {
-	// TODO move this to struct as it should be shared
-	ctx := context.TODO()
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to load SDK config: %w", err)
+// ssmInstanceIDBatch is the number of instance IDs sent in a single
+// InstanceIds filter. AWS rejects larger filter value lists.
+const ssmInstanceIDBatch = 50
+
+// SSMState is how SSM sees a managed instance. PingStatus is Online,
+// ConnectionLost or Inactive, and LatestAgent is false when a newer SSM Agent
+// is available for the platform.
+type SSMState struct {
+	AgentVersion      string    `json:"agent_version"`
+	LatestAgent       bool      `json:"latest_agent"`
+	PingStatus        string    `json:"ping_status"`
+	LastPing          time.Time `json:"last_ping"`
+	PlatformType      string    `json:"platform_type"`
+	PlatformName      string    `json:"platform_name"`
+	PlatformVersion   string    `json:"platform_version"`
+	AssociationStatus string    `json:"association_status"`
+	LastAssociation   time.Time `json:"last_association"`
+}
+
+// Online reports whether the agent is checking in.
+func (state *SSMState) Online() bool {
+	return state.PingStatus == string(types.PingStatusOnline)
+}
+
+// ConnectionLost reports whether the agent has stopped checking in.
+func (state *SSMState) ConnectionLost() bool {
+	return state.PingStatus == string(types.PingStatusConnectionLost)
+}
+
+// AgentOutdated reports whether a newer SSM Agent is available.
+func (state *SSMState) AgentOutdated() bool {
+	return !state.LatestAgent
+}
+
+// Scan through all instances and set the IsSSM flag and the SSM state. Only
+// the instances already in the Instances field are looked up, in batches, and
+// every result page is read. Additional SSM filters, e.g. on PlatformTypes or tag keys, can
+// be supplied to narrow the lookup further.
+func (mgr *EC2InstanceManager) FetchSSMDetails(filters ...types.InstanceInformationStringFilter) error {
+	if len(mgr.Instances) == 0 {
+		return nil
 	}
 
-	ssmClient := ssm.NewFromConfig(cfg)
-	ssmOutput, err := ssmClient.DescribeInstanceInformation(ctx, &ssm.DescribeInstanceInformationInput{})
-	if err != nil {
-		return fmt.Errorf("cannot describe SSM instance information, %v", err)
+	if mgr.SSMClient == nil {
+		return fmt.Errorf("cannot describe SSM instance information without an SSM client")
 	}
 
-	ssmManaged := make(map[string]bool)
-	for _, instance := range ssmOutput.InstanceInformationList {
-		ssmManaged[*instance.InstanceId] = true
+	ctx := context.TODO()
+	states := map[string]*SSMState{}
+	for start := 0; start < len(mgr.Instances); start += ssmInstanceIDBatch {
+		end := start + ssmInstanceIDBatch
+		if end > len(mgr.Instances) {
+			end = len(mgr.Instances)
+		}
+
+		ids := make([]string, 0, end-start)
+		for _, instance := range mgr.Instances[start:end] {
+			ids = append(ids, instance.ID)
+		}
+
+		input := &ssm.DescribeInstanceInformationInput{
+			Filters: append([]types.InstanceInformationStringFilter{
+				{Key: aws.String("InstanceIds"), Values: ids},
+			}, filters...),
+		}
+
+		paginator := ssm.NewDescribeInstanceInformationPaginator(mgr.SSMClient, input)
+		for paginator.HasMorePages() {
+			ssmOutput, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("cannot describe SSM instance information, %v", err)
+			}
+
+			for _, info := range ssmOutput.InstanceInformationList {
+				states[aws.ToString(info.InstanceId)] = newSSMState(info)
+			}
+		}
 	}
 
 	for i := range mgr.Instances {
-		mgr.Instances[i].IsSSM = ssmManaged[mgr.Instances[i].ID]
+		mgr.Instances[i].SSM = states[mgr.Instances[i].ID]
+		mgr.Instances[i].IsSSM = mgr.Instances[i].SSM != nil
 	}
 
 	return nil
 }
+
+// newSSMState converts SSM instance information. An agent whose version is
+// not known to be outdated is taken as the latest.
+func newSSMState(info types.InstanceInformation) *SSMState {
+	return &SSMState{
+		AgentVersion:      aws.ToString(info.AgentVersion),
+		LatestAgent:       info.IsLatestVersion == nil || *info.IsLatestVersion,
+		PingStatus:        string(info.PingStatus),
+		LastPing:          aws.ToTime(info.LastPingDateTime),
+		PlatformType:      string(info.PlatformType),
+		PlatformName:      aws.ToString(info.PlatformName),
+		PlatformVersion:   aws.ToString(info.PlatformVersion),
+		AssociationStatus: aws.ToString(info.AssociationStatus),
+		LastAssociation:   aws.ToTime(info.LastAssociationExecutionDate),
+	}
+}
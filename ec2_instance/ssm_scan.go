@@ -0,0 +1,82 @@
+package ec2_instance
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// scanSeparator marks the end of the output of each scan command when they
+// are run as one SSM script.
+const scanSeparator = "--- kci scan ---"
+
+// SSMScan runs a Scan on all Instance items with SSM Run Command instead of
+// SSH, so no bastion or SSH keys are needed. Instances must be managed by SSM,
+// see FetchSSMDetails; those that are not, or whose scan fails, are logged and
+// marked "No Connection". Concurrency, Timeout and Progress of opts are used,
+// the SSH settings are ignored.
+func (mgr *EC2InstanceManager) SSMScan(opts ScanOptions) error {
+	var script []string
+	for _, command := range scanCommands {
+		script = append(script, command, "echo '"+scanSeparator+"'")
+	}
+
+	// a failed scan must not stop the others
+	req := CommandRequest{
+		Commands:  script,
+		Comment:   "kci instance scan",
+		Timeout:   opts.Timeout,
+		MaxErrors: "100%",
+		Progress:  opts.Progress,
+	}
+	if opts.Concurrency > 0 {
+		req.MaxConcurrency = fmt.Sprint(opts.Concurrency)
+	}
+
+	var ids []string
+	var indexes []int
+	for i, instance := range mgr.Instances {
+		if !instance.IsSSM {
+			log.Printf("target scan failed (%s): not managed by SSM", instance.Name)
+			mgr.Instances[i].Status = "No Connection"
+			continue
+		}
+
+		ids = append(ids, instance.ID)
+		indexes = append(indexes, i)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	results, err := mgr.RunCommand(ids, req)
+	if err != nil {
+		return err
+	}
+
+	for n, result := range results {
+		instance := &mgr.Instances[indexes[n]]
+
+		err := result.Err
+		if err == nil && result.Status != "Success" {
+			err = fmt.Errorf("command %s with exit code %d: %s", result.Status, result.ExitCode, strings.TrimSpace(result.Error))
+		}
+		if err != nil {
+			log.Printf("target scan failed (%s): %v", instance.Name, err)
+			instance.Status = "No Connection"
+			continue
+		}
+
+		output := strings.Split(result.Output, scanSeparator+"\n")
+		if len(output) < len(scanCommands) {
+			log.Printf("target scan failed (%s): unexpected output %q", instance.Name, result.Output)
+			instance.Status = "No Connection"
+			continue
+		}
+
+		instance.parseScan(output)
+	}
+
+	return nil
+}
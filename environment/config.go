@@ -0,0 +1,139 @@
+package environment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the kci user configuration.
+//
+// Defaults holds per-command flag defaults keyed by command path, e.g.
+// "instance scan", with the special key "global" applying to every command
+// that has the flag. The first environment is used when no environment is
+// selected.
+type Config struct {
+	Path         string                       `yaml:"-" json:"path"`
+	Environments []Environment                `yaml:"environments" json:"environments"`
+	Defaults     map[string]map[string]string `yaml:"defaults,omitempty" json:"defaults"`
+}
+
+// Builtin returns the configuration used when no configuration file exists.
+func Builtin() *Config {
+	return &Config{
+		Environments: []Environment{
+			{Name: "dit", Profile: "dit", StatusURL: "https://kcs-dev.kineticcommercetech.io"},
+			{Name: "stage", Profile: "stage", StatusURL: "https://kcs-staging.kineticcommercetech.io", Label: "staging"},
+			{Name: "prod", Profile: "prod", StatusURL: "https://kcs.kineticcommerce.io", Protected: true},
+			{Name: "prod-eu", Profile: "prod-eu", StatusURL: "https://kcs-prod-eu-platform.kineticcommerce.io", Protected: true},
+		},
+		Defaults: map[string]map[string]string{},
+	}
+}
+
+// ConfigPath returns the location of the configuration file: $KCI_CONFIG when
+// set, ~/.config/kci/config.yaml otherwise.
+func ConfigPath() (string, error) {
+	if path := os.Getenv("KCI_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "kci", "config.yaml"), nil
+}
+
+// LoadConfig reads and validates the configuration file at path. When the
+// default configuration file does not exist the builtin configuration is
+// returned; a missing file named by $KCI_CONFIG is an error.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && os.Getenv("KCI_CONFIG") == "" {
+		return Builtin(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config: %w", err)
+	}
+
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config %s: %w", path, err)
+	}
+	cfg.Path = path
+
+	if cfg.Defaults == nil {
+		cfg.Defaults = map[string]map[string]string{}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that the configuration is internally consistent.
+func (cfg *Config) Validate() error {
+	if len(cfg.Environments) == 0 {
+		return fmt.Errorf("no environments defined")
+	}
+
+	seen := map[string]bool{}
+	for i, env := range cfg.Environments {
+		if env.Name == "" {
+			return fmt.Errorf("environment %d has no name", i+1)
+		}
+		if seen[env.Name] {
+			return fmt.Errorf("environment %s is defined more than once", env.Name)
+		}
+		seen[env.Name] = true
+
+		if env.StatusURL != "" {
+			u, err := url.Parse(env.StatusURL)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("environment %s has an invalid status_url %q", env.Name, env.StatusURL)
+			}
+		}
+
+		if env.ParamPrefix != "" && !strings.HasPrefix(env.ParamPrefix, "/") {
+			return fmt.Errorf("environment %s has an invalid param_prefix %q, it must start with /", env.Name, env.ParamPrefix)
+		}
+	}
+
+	return nil
+}
+
+// Names returns the names of all configured environments.
+func (cfg *Config) Names() []string {
+	names := make([]string, len(cfg.Environments))
+	for i, env := range cfg.Environments {
+		names[i] = env.Name
+	}
+
+	return names
+}
+
+// Lookup returns the environment with the given name.
+func (cfg *Config) Lookup(name string) (Environment, error) {
+	for _, env := range cfg.Environments {
+		if env.Name == name {
+			return env, nil
+		}
+	}
+
+	return Environment{}, fmt.Errorf("unknown environment %q", name)
+}
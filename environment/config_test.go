@@ -0,0 +1,172 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		envs    []Environment
+		wantErr string
+	}{
+		{
+			name: "valid",
+			envs: []Environment{
+				{Name: "dit", StatusURL: "https://kcs-dev.example.com"},
+				{Name: "prod", ParamPrefix: "/production"},
+			},
+		},
+		{
+			name:    "no environments",
+			wantErr: "no environments defined",
+		},
+		{
+			name:    "missing name",
+			envs:    []Environment{{Name: "dit"}, {Profile: "stage"}},
+			wantErr: "environment 2 has no name",
+		},
+		{
+			name:    "duplicate name",
+			envs:    []Environment{{Name: "dit"}, {Name: "dit"}},
+			wantErr: "environment dit is defined more than once",
+		},
+		{
+			name:    "status url without scheme",
+			envs:    []Environment{{Name: "dit", StatusURL: "kcs-dev.example.com"}},
+			wantErr: "invalid status_url",
+		},
+		{
+			name:    "relative param prefix",
+			envs:    []Environment{{Name: "dit", ParamPrefix: "dit"}},
+			wantErr: "invalid param_prefix",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Environments: tt.envs}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	cfg := Builtin()
+
+	err := cfg.Validate()
+	if err != nil {
+		t.Fatalf("Builtin().Validate() = %v", err)
+	}
+
+	want := []string{"dit", "stage", "prod", "prod-eu"}
+	if got := strings.Join(cfg.Names(), ","); got != strings.Join(want, ",") {
+		t.Errorf("Builtin().Names() = %s, want %s", got, strings.Join(want, ","))
+	}
+
+	for _, name := range []string{"prod", "prod-eu"} {
+		env, err := cfg.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !env.Protected {
+			t.Errorf("builtin %s is not protected", name)
+		}
+	}
+
+	stage, _ := cfg.Lookup("stage")
+	if stage.DisplayName() != "staging" {
+		t.Errorf("builtin stage DisplayName() = %q, want staging", stage.DisplayName())
+	}
+
+	_, err = cfg.Lookup("qa")
+	if err == nil {
+		t.Error("Lookup(qa) succeeded, want an error")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(data), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		envConfig bool
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "valid",
+			path: write("valid.yaml", "environments:\n  - name: dit\n  - name: prod\n    protected: true\n"),
+			want: []string{"dit", "prod"},
+		},
+		{
+			name:    "unknown field",
+			path:    write("unknown.yaml", "environments:\n  - name: dit\n    jumphost: bastion\n"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			path:    write("invalid.yaml", "environments: []\n"),
+			wantErr: true,
+		},
+		{
+			name: "missing default file",
+			path: filepath.Join(dir, "missing.yaml"),
+			want: []string{"dit", "stage", "prod", "prod-eu"},
+		},
+		{
+			name:      "missing KCI_CONFIG file",
+			path:      filepath.Join(dir, "missing.yaml"),
+			envConfig: true,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kciConfig := ""
+			if tt.envConfig {
+				kciConfig = tt.path
+			}
+			t.Setenv("KCI_CONFIG", kciConfig)
+
+			cfg, err := LoadConfig(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := strings.Join(cfg.Names(), ","); got != strings.Join(tt.want, ",") {
+				t.Errorf("LoadConfig() environments = %s, want %s", got, strings.Join(tt.want, ","))
+			}
+			if cfg.Defaults == nil {
+				t.Error("LoadConfig() Defaults is nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Package environment maps KCS environments to the AWS profile, region and
+// account they live in, and provides the shared AWS config loader used by all
+// managers.
+package environment
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Environment describes where a KCS environment lives in AWS and how to reach
+// it.
+//
+// Profile is the shared config profile used for credentials. Region overrides
+// the region of the profile when set. RoleARN, when set, is assumed on top of
+// the profile credentials. AccountID, when set, is checked against the caller
+// identity so that a misconfigured profile cannot silently query the wrong
+// account.
+//
+// StatusURL is the base URL of the platform `status` endpoints, and Label the
+// name the sysinfo commands show for the environment, Name when empty.
+//
+// JumpHost and JumpUser are the bastion used to reach private instances;
+// JumpHost may be a comma separated chain of bastions. ScanUser is the user
+// logged in as on those instances. Empty SSH settings fall back to
+// ~/.ssh/config.
+//
+// ParamPrefix is the Parameter Store path holding the environment's
+// parameters, "/<name>" when empty. Protected environments ask for
+// confirmation before commands that change instances or parameters, such as
+// applying patches.
+type Environment struct {
+	Name        string `yaml:"name" json:"name"`
+	Profile     string `yaml:"profile,omitempty" json:"profile"`
+	Region      string `yaml:"region,omitempty" json:"region"`
+	RoleARN     string `yaml:"role_arn,omitempty" json:"role_arn"`
+	AccountID   string `yaml:"account_id,omitempty" json:"account_id"`
+	StatusURL   string `yaml:"status_url,omitempty" json:"status_url"`
+	Label       string `yaml:"label,omitempty" json:"label"`
+	JumpHost    string `yaml:"jump_host,omitempty" json:"jump_host"`
+	JumpUser    string `yaml:"jump_user,omitempty" json:"jump_user"`
+	ScanUser    string `yaml:"scan_user,omitempty" json:"scan_user"`
+	ParamPrefix string `yaml:"param_prefix,omitempty" json:"param_prefix"`
+	Protected   bool   `yaml:"protected,omitempty" json:"protected"`
+}
+
+// ParameterPrefix returns the Parameter Store path of the environment.
+func (env Environment) ParameterPrefix() string {
+	if env.ParamPrefix != "" {
+		return env.ParamPrefix
+	}
+
+	return "/" + env.Name
+}
+
+// DisplayName returns the label of the environment, or its name.
+func (env Environment) DisplayName() string {
+	if env.Label != "" {
+		return env.Label
+	}
+
+	return env.Name
+}
+
+// LoadAWSConfig loads the AWS config for the environment. Any extra load
+// options are applied after the environment's own.
+func (env Environment) LoadAWSConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+
+	if env.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(env.Profile))
+	}
+	if env.Region != "" {
+		opts = append(opts, config.WithRegion(env.Region))
+	}
+	opts = append(opts, optFns...)
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to load AWS config for %s: %w", env.Name, err)
+	}
+
+	if env.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), env.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = "kci-" + env.Name
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	if env.AccountID != "" {
+		err = env.verifyAccount(ctx, cfg)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// verifyAccount checks that the loaded credentials belong to the environment's
+// AWS account.
+func (env Environment) verifyAccount(ctx context.Context, cfg aws.Config) error {
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("unable to verify AWS account for %s: %w", env.Name, err)
+	}
+
+	account := aws.ToString(identity.Account)
+	if account != env.AccountID {
+		return fmt.Errorf("environment %s expects AWS account %s but credentials are for %s", env.Name, env.AccountID, account)
+	}
+
+	return nil
+}
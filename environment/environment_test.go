@@ -0,0 +1,39 @@
+package environment
+
+import "testing"
+
+func TestParameterPrefix(t *testing.T) {
+	tests := []struct {
+		env  Environment
+		want string
+	}{
+		{Environment{Name: "dit"}, "/dit"},
+		{Environment{Name: "prod", ParamPrefix: "/production"}, "/production"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.Name, func(t *testing.T) {
+			if got := tt.env.ParameterPrefix(); got != tt.want {
+				t.Errorf("ParameterPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		env  Environment
+		want string
+	}{
+		{Environment{Name: "dit"}, "dit"},
+		{Environment{Name: "stage", Label: "staging"}, "staging"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.Name, func(t *testing.T) {
+			if got := tt.env.DisplayName(); got != tt.want {
+				t.Errorf("DisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
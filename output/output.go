@@ -0,0 +1,199 @@
+// Package output provides a shared rendering layer for command results. A
+// Report can be rendered as a human readable table or as JSON, YAML, CSV or
+// TSV for consumption by scripts.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the name of an output format.
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	CSV   Format = "csv"
+	TSV   Format = "tsv"
+)
+
+// Formats lists all supported output formats.
+var Formats = []Format{Table, JSON, YAML, CSV, TSV}
+
+// ParseFormat returns the Format named by s, or an error if the format is not
+// supported.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid output format %q, must be one of %s", s, FormatNames())
+}
+
+// FormatNames returns the supported formats as a comma separated string for use
+// in help and error messages.
+func FormatNames() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Report holds the result of a command. Headers and Rows are used for the
+// tabular formats (table, csv, tsv). Data is used for the structured formats
+// (json, yaml) and is serialized through its `json` struct tags.
+type Report struct {
+	Headers []string
+	Rows    [][]string
+	Data    interface{}
+}
+
+// NewReport creates an empty Report with the given headers.
+func NewReport(headers ...string) *Report {
+	return &Report{
+		Headers: headers,
+		Rows:    [][]string{},
+	}
+}
+
+// Append adds a row to the tabular form of the report.
+func (r *Report) Append(row []string) {
+	r.Rows = append(r.Rows, row)
+}
+
+// Render writes the report to w in the requested format.
+func (r *Report) Render(w io.Writer, format Format) error {
+	switch format {
+	case Table, "":
+		return r.renderTable(w)
+	case JSON:
+		return r.renderJSON(w)
+	case YAML:
+		return r.renderYAML(w)
+	case CSV:
+		return r.renderDelimited(w, ',')
+	case TSV:
+		return r.renderDelimited(w, '\t')
+	}
+
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+func (r *Report) renderTable(w io.Writer) error {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(r.Headers)
+	table.AppendBulk(r.Rows)
+	table.Render()
+
+	return nil
+}
+
+func (r *Report) renderDelimited(w io.Writer, comma rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	err := writer.Write(r.Headers)
+	if err != nil {
+		return fmt.Errorf("unable to write header: %w", err)
+	}
+
+	err = writer.WriteAll(r.Rows)
+	if err != nil {
+		return fmt.Errorf("unable to write rows: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Report) renderJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(r.data())
+	if err != nil {
+		return fmt.Errorf("unable to encode json: %w", err)
+	}
+
+	return nil
+}
+
+// renderYAML goes through JSON first so that the `json` struct tags already on
+// our types are honoured. JSON is valid YAML, so decoding it into a yaml.Node
+// keeps the field order; the node styles are then reset to get block output.
+func (r *Report) renderYAML(w io.Writer) error {
+	raw, err := json.Marshal(r.data())
+	if err != nil {
+		return fmt.Errorf("unable to encode yaml: %w", err)
+	}
+
+	var node yaml.Node
+	err = yaml.Unmarshal(raw, &node)
+	if err != nil {
+		return fmt.Errorf("unable to encode yaml: %w", err)
+	}
+	resetStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(&node)
+	if err != nil {
+		return fmt.Errorf("unable to encode yaml: %w", err)
+	}
+
+	return encoder.Close()
+}
+
+// data returns the structured form of the report. When no Data was supplied,
+// the rows are converted to a list of objects keyed by header.
+func (r *Report) data() interface{} {
+	if r.Data != nil {
+		return r.Data
+	}
+
+	records := []map[string]string{}
+	for _, row := range r.Rows {
+		record := map[string]string{}
+		for i, header := range r.Headers {
+			if i < len(row) {
+				record[fieldName(header)] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// fieldName converts a table header such as "Instance Age" to instance_age.
+func fieldName(header string) string {
+	var buf bytes.Buffer
+	for _, word := range strings.Fields(strings.ToLower(header)) {
+		if buf.Len() > 0 {
+			buf.WriteByte('_')
+		}
+		buf.WriteString(strings.Trim(word, "()"))
+	}
+
+	return buf.String()
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
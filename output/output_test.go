@@ -0,0 +1,180 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Format
+		wantErr bool
+	}{
+		{"table", Table, false},
+		{"JSON", JSON, false},
+		{"yaml", YAML, false},
+		{"csv", CSV, false},
+		{"tsv", TSV, false},
+		{"xml", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFormat(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	rows := func() *Report {
+		report := NewReport("Name", "Instance Age", "IP (private)")
+		report.Append([]string{"web-1", "3d", "10.0.0.1"})
+		report.Append([]string{"web, 2", "1d", "10.0.0.2"})
+		return report
+	}
+
+	type instance struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	data := func() *Report {
+		report := rows()
+		report.Data = []instance{{ID: "i-1", Name: "web-1"}, {ID: "i-2"}}
+		return report
+	}
+
+	tests := []struct {
+		name   string
+		report *Report
+		format Format
+		want   string
+	}{
+		{
+			name:   "csv quotes commas",
+			report: rows(),
+			format: CSV,
+			want:   "Name,Instance Age,IP (private)\nweb-1,3d,10.0.0.1\n\"web, 2\",1d,10.0.0.2\n",
+		},
+		{
+			name:   "tsv",
+			report: rows(),
+			format: TSV,
+			want:   "Name\tInstance Age\tIP (private)\nweb-1\t3d\t10.0.0.1\nweb, 2\t1d\t10.0.0.2\n",
+		},
+		{
+			name:   "json from rows",
+			report: rows(),
+			format: JSON,
+			want: `[
+  {
+    "instance_age": "3d",
+    "ip_private": "10.0.0.1",
+    "name": "web-1"
+  },
+  {
+    "instance_age": "1d",
+    "ip_private": "10.0.0.2",
+    "name": "web, 2"
+  }
+]
+`,
+		},
+		{
+			name:   "json from data",
+			report: data(),
+			format: JSON,
+			want: `[
+  {
+    "id": "i-1",
+    "name": "web-1"
+  },
+  {
+    "id": "i-2"
+  }
+]
+`,
+		},
+		{
+			name:   "yaml keeps the json field order",
+			report: data(),
+			format: YAML,
+			want: `- id: i-1
+  name: web-1
+- id: i-2
+`,
+		},
+		{
+			name:   "empty json is a list",
+			report: NewReport("Name"),
+			format: JSON,
+			want:   "[]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := tt.report.Render(&buf, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render(%s) =\n%s\nwant\n%s", tt.format, buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	report := NewReport("Name", "State")
+	report.Append([]string{"web-1", "running"})
+
+	for _, format := range []Format{Table, ""} {
+		var buf bytes.Buffer
+		err := report.Render(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{"NAME", "STATE", "web-1", "running"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Render(%q) = %s, want %s in it", format, buf.String(), want)
+			}
+		}
+	}
+
+	err := report.Render(&bytes.Buffer{}, "xml")
+	if err == nil {
+		t.Error("Render(xml) succeeded, want an error")
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"Name", "name"},
+		{"Instance Age", "instance_age"},
+		{"IP (private)", "ip_private"},
+		{"  SSM   Agent ", "ssm_agent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := fieldName(tt.header)
+			if got != tt.want {
+				t.Errorf("fieldName(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
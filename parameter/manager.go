@@ -0,0 +1,44 @@
+package parameter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// ParameterManager reads and writes parameters below Prefix, e.g. "/prod".
+type ParameterManager struct {
+	Client *ssm.Client
+	Prefix string
+}
+
+// NewManagerWithClient creates a new ParameterManager with a supplied aws client.
+func NewManagerWithClient(client *ssm.Client, prefix string) *ParameterManager {
+	return &ParameterManager{
+		Client: client,
+		Prefix: "/" + strings.Trim(prefix, "/"),
+	}
+}
+
+// NewManager creates a new ParameterManager with a client for the given AWS
+// config.
+func NewManager(cfg aws.Config, prefix string) (*ParameterManager, error) {
+	client := ssm.NewFromConfig(cfg)
+
+	return NewManagerWithClient(client, prefix), nil
+}
+
+// FullName returns the name of a parameter in Parameter Store. Names are
+// always taken relative to the prefix, with or without a leading slash, and
+// may not escape it.
+func (mgr *ParameterManager) FullName(name string) (string, error) {
+	full := path.Join(mgr.Prefix, strings.TrimPrefix(name, "/"))
+	if full != mgr.Prefix && !strings.HasPrefix(full, strings.TrimSuffix(mgr.Prefix, "/")+"/") {
+		return "", fmt.Errorf("parameter %s is outside of %s", name, mgr.Prefix)
+	}
+
+	return full, nil
+}
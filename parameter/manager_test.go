@@ -0,0 +1,37 @@
+package parameter
+
+import "testing"
+
+func TestFullName(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"/prod", "db/password", "/prod/db/password", false},
+		{"/prod", "/db/password", "/prod/db/password", false},
+		{"prod/", "db/password", "/prod/db/password", false},
+		{"/prod", "", "/prod", false},
+		{"/prod", "/", "/prod", false},
+		{"/prod", "db/../api/key", "/prod/api/key", false},
+		{"/prod", "../dit/db/password", "", true},
+		{"/prod", "/../prod-eu/key", "", true},
+		{"/prod", "..", "", true},
+		{"", "dit/key", "/dit/key", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.name, func(t *testing.T) {
+			mgr := NewManagerWithClient(nil, tt.prefix)
+
+			got, err := mgr.FullName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FullName(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FullName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
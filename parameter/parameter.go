@@ -0,0 +1,213 @@
+// Package parameter provides AWS SSM Parameter Store helper methods. Every
+// parameter name is relative to the manager's Prefix, so that one environment
+// cannot read or change the parameters of another by accident.
+package parameter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Parameter types.
+const (
+	TypeString       = string(types.ParameterTypeString)
+	TypeStringList   = string(types.ParameterTypeStringList)
+	TypeSecureString = string(types.ParameterTypeSecureString)
+)
+
+// ErrNotFound is returned when a parameter does not exist.
+var ErrNotFound = errors.New("parameter not found")
+
+// Parameter is a parameter, or one version of it. The value of a SecureString
+// is only set when it was read with decryption. ModifiedBy is only known for
+// history entries.
+type Parameter struct {
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	Value        string    `json:"value"`
+	Version      int64     `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+	ModifiedBy   string    `json:"modified_by,omitempty"`
+}
+
+// List returns the parameters below a path, relative to the prefix. Nested
+// paths are only included when recursive is set. SecureString values are left
+// empty unless decrypt is set.
+func (mgr *ParameterManager) List(dir string, recursive bool, decrypt bool) ([]Parameter, error) {
+	full, err := mgr.FullName(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var parameters []Parameter
+
+	paginator := ssm.NewGetParametersByPathPaginator(mgr.Client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(full),
+		Recursive:      aws.Bool(recursive),
+		WithDecryption: aws.Bool(decrypt),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("unable to list parameters: %w", err)
+		}
+
+		for _, p := range resp.Parameters {
+			parameters = append(parameters, newParameter(p, decrypt))
+		}
+	}
+
+	return parameters, nil
+}
+
+// Get returns a parameter. Its SecureString value is left empty unless
+// decrypt is set.
+func (mgr *ParameterManager) Get(name string, decrypt bool) (Parameter, error) {
+	full, err := mgr.FullName(name)
+	if err != nil {
+		return Parameter{}, err
+	}
+
+	resp, err := mgr.Client.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           aws.String(full),
+		WithDecryption: aws.Bool(decrypt),
+	})
+
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return Parameter{}, fmt.Errorf("%s: %w", full, ErrNotFound)
+	}
+	if err != nil {
+		return Parameter{}, fmt.Errorf("unable to get parameter %s: %w", full, err)
+	}
+
+	return newParameter(*resp.Parameter, decrypt), nil
+}
+
+// Put creates a parameter, or replaces its value when overwrite is set. The
+// type of an existing parameter cannot be changed; an empty parameterType
+// keeps it, or is a String for a new parameter. It returns the new version.
+func (mgr *ParameterManager) Put(name string, value string, parameterType string, overwrite bool) (int64, error) {
+	full, err := mgr.FullName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	existing, err := mgr.Get(name, false)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if parameterType == "" {
+			parameterType = TypeString
+		}
+	case err != nil:
+		return 0, err
+	case !overwrite:
+		return 0, fmt.Errorf("parameter %s already exists at version %d, use --overwrite to replace it", full, existing.Version)
+	case parameterType == "":
+		parameterType = existing.Type
+	case existing.Type != parameterType:
+		return 0, fmt.Errorf("parameter %s is a %s, delete it first to change it to a %s", full, existing.Type, parameterType)
+	}
+
+	resp, err := mgr.Client.PutParameter(context.TODO(), &ssm.PutParameterInput{
+		Name:      aws.String(full),
+		Value:     aws.String(value),
+		Type:      types.ParameterType(parameterType),
+		Overwrite: aws.Bool(overwrite),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to put parameter %s: %w", full, err)
+	}
+
+	return resp.Version, nil
+}
+
+// Delete deletes a parameter and its history.
+func (mgr *ParameterManager) Delete(name string) error {
+	full, err := mgr.FullName(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = mgr.Client.DeleteParameter(context.TODO(), &ssm.DeleteParameterInput{
+		Name: aws.String(full),
+	})
+
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%s: %w", full, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to delete parameter %s: %w", full, err)
+	}
+
+	return nil
+}
+
+// History returns every version of a parameter, oldest first. SecureString
+// values are left empty unless decrypt is set.
+func (mgr *ParameterManager) History(name string, decrypt bool) ([]Parameter, error) {
+	full, err := mgr.FullName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Parameter
+
+	paginator := ssm.NewGetParameterHistoryPaginator(mgr.Client, &ssm.GetParameterHistoryInput{
+		Name:           aws.String(full),
+		WithDecryption: aws.Bool(decrypt),
+	})
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(context.TODO())
+
+		var notFound *types.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%s: %w", full, ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get history of %s: %w", full, err)
+		}
+
+		for _, h := range resp.Parameters {
+			version := Parameter{
+				Name:         aws.ToString(h.Name),
+				Type:         string(h.Type),
+				Value:        aws.ToString(h.Value),
+				Version:      h.Version,
+				LastModified: aws.ToTime(h.LastModifiedDate),
+				ModifiedBy:   aws.ToString(h.LastModifiedUser),
+			}
+			if version.Type == TypeSecureString && !decrypt {
+				version.Value = ""
+			}
+
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+func newParameter(p types.Parameter, decrypt bool) Parameter {
+	parameter := Parameter{
+		Name:         aws.ToString(p.Name),
+		Type:         string(p.Type),
+		Value:        aws.ToString(p.Value),
+		Version:      p.Version,
+		LastModified: aws.ToTime(p.LastModifiedDate),
+	}
+
+	// without decryption the value is the ciphertext
+	if parameter.Type == TypeSecureString && !decrypt {
+		parameter.Value = ""
+	}
+
+	return parameter
+}
@@ -0,0 +1,303 @@
+// Package replay records HTTP interactions to a directory and plays them back,
+// so that commands can be reproduced without network access. Both the AWS SDK
+// and the status endpoint calls go through an http.Client, so wrapping its
+// transport captures everything kci talks to over HTTP.
+//
+// Each interaction is stored as one JSON file named after the request. A request
+// is identified by its method, URL and body; identical requests are numbered in
+// the order they were made. When replaying more identical requests than were
+// recorded, the last recording is returned again, which keeps pollers and
+// waiters working.
+//
+// Recordings are readable by their owner only. Requests for credentials are
+// not recorded, and credentials, session tokens and SecureString parameter
+// values are redacted from the rest; request bodies are identified after
+// redaction, so secrets do not even leave a hash behind. Other response data,
+// such as instance and database names, is kept in clear text.
+package replay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// metaFile holds the Meta of a recording.
+const metaFile = "meta.json"
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	RequestBody string      `json:"request_body,omitempty"`
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+	BodyBase64  bool        `json:"body_base64,omitempty"`
+}
+
+// Meta describes the circumstances of a recording. It is needed to replay AWS
+// calls, since the region is part of every endpoint.
+type Meta struct {
+	Environment string    `json:"environment"`
+	Region      string    `json:"region"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// SaveMeta writes the recording metadata to dir.
+func SaveMeta(dir string, meta Meta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode recording metadata: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(dir, metaFile), data, 0o600)
+}
+
+// LoadMeta reads the recording metadata from dir.
+func LoadMeta(dir string) (Meta, error) {
+	var meta Meta
+
+	data, err := os.ReadFile(filepath.Join(dir, metaFile))
+	if err != nil {
+		return meta, fmt.Errorf("unable to read recording metadata: %w", err)
+	}
+
+	err = json.Unmarshal(data, &meta)
+	if err != nil {
+		return meta, fmt.Errorf("unable to decode recording metadata: %w", err)
+	}
+
+	return meta, nil
+}
+
+// Recorder is an http.RoundTripper that performs requests with an underlying
+// transport and saves every interaction, except requests for credentials, to a
+// directory.
+type Recorder struct {
+	dir       string
+	transport http.RoundTripper
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewRecorder creates a Recorder saving to dir, creating it if needed. A nil
+// transport uses http.DefaultTransport.
+func NewRecorder(dir string, transport http.RoundTripper) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create recording directory: %w", err)
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{
+		dir:       dir,
+		transport: transport,
+		counts:    map[string]int{},
+	}, nil
+}
+
+// RoundTrip performs the request and records the interaction with its secrets
+// redacted.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isCredentialRequest(req) {
+		return r.transport.RoundTrip(req)
+	}
+
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response for recording: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	reqBody = scrubRequest(reqBody)
+	body = scrubResponse(body)
+
+	interaction := Interaction{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(reqBody),
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+	}
+	if utf8.Valid(body) {
+		interaction.Body = string(body)
+	} else {
+		interaction.Body = base64.StdEncoding.EncodeToString(body)
+		interaction.BodyBase64 = true
+	}
+
+	key := requestKey(req, reqBody)
+
+	r.mu.Lock()
+	n := r.counts[key]
+	r.counts[key] = n + 1
+	r.mu.Unlock()
+
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode recording: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(r.dir, fileName(key, n)), data, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to save recording: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Player is an http.RoundTripper that answers requests from a recording
+// directory without touching the network.
+type Player struct {
+	dir string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewPlayer creates a Player reading from dir.
+func NewPlayer(dir string) (*Player, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open recording: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("recording %s is not a directory", dir)
+	}
+
+	return &Player{
+		dir:    dir,
+		counts: map[string]int{},
+	}, nil
+}
+
+// RoundTrip returns the recorded response for the request.
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := requestKey(req, scrubRequest(reqBody))
+
+	p.mu.Lock()
+	n := p.counts[key]
+	p.counts[key] = n + 1
+	p.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(p.dir, fileName(key, n)))
+	for errors.Is(err, os.ErrNotExist) && n > 0 {
+		n--
+		data, err = os.ReadFile(filepath.Join(p.dir, fileName(key, n)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s: %w", req.Method, req.URL, err)
+	}
+
+	var interaction Interaction
+	err = json.Unmarshal(data, &interaction)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode recording %s: %w", fileName(key, n), err)
+	}
+
+	body := []byte(interaction.Body)
+	if interaction.BodyBase64 {
+		body, err = base64.StdEncoding.DecodeString(interaction.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode recording %s: %w", fileName(key, n), err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.StatusCode, http.StatusText(interaction.StatusCode)),
+		StatusCode:    interaction.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the request body and replaces it so that the request
+// can still be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// requestKey identifies a request by method, URL and body. The key starts with
+// the host and API operation to keep recordings readable.
+func requestKey(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+	hash.Write(body)
+
+	name := req.URL.Hostname() + "-" + operation(req, body)
+	name = strings.Trim(unsafeChars.ReplaceAllString(name, "_"), "_")
+
+	return name + "-" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// operation returns the AWS API operation of a request, or its path for plain
+// HTTP requests. JSON protocol services name it in X-Amz-Target, query
+// protocol services in the Action parameter.
+func operation(req *http.Request, body []byte) string {
+	if target := req.Header.Get("X-Amz-Target"); target != "" {
+		return target[strings.LastIndex(target, ".")+1:]
+	}
+
+	if values, err := url.ParseQuery(string(body)); err == nil && values.Get("Action") != "" {
+		return values.Get("Action")
+	}
+
+	return req.URL.Path
+}
+
+func fileName(key string, n int) string {
+	return fmt.Sprintf("%s-%03d.json", key, n)
+}
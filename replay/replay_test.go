@@ -0,0 +1,178 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequestKey(t *testing.T) {
+	request := func(method string, url string, target string, body string) (*http.Request, []byte) {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != "" {
+			req.Header.Set("X-Amz-Target", target)
+		}
+
+		return req, []byte(body)
+	}
+
+	base, baseBody := request(http.MethodPost, "https://ssm.us-east-1.amazonaws.com/", "AmazonSSM.DescribeInstanceInformation", `{"MaxResults":50}`)
+	baseKey := requestKey(base, baseBody)
+
+	if !strings.HasPrefix(baseKey, "ssm.us-east-1.amazonaws.com-DescribeInstanceInformation-") {
+		t.Errorf("requestKey() = %q, want host and operation first", baseKey)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		target string
+		body   string
+		same   bool
+	}{
+		{"identical", http.MethodPost, "https://ssm.us-east-1.amazonaws.com/", "AmazonSSM.DescribeInstanceInformation", `{"MaxResults":50}`, true},
+		{"header ignored", http.MethodPost, "https://ssm.us-east-1.amazonaws.com/", "", `{"MaxResults":50}`, true},
+		{"body", http.MethodPost, "https://ssm.us-east-1.amazonaws.com/", "AmazonSSM.DescribeInstanceInformation", `{"MaxResults":50,"NextToken":"a"}`, false},
+		{"method", http.MethodPut, "https://ssm.us-east-1.amazonaws.com/", "AmazonSSM.DescribeInstanceInformation", `{"MaxResults":50}`, false},
+		{"region", http.MethodPost, "https://ssm.eu-west-1.amazonaws.com/", "AmazonSSM.DescribeInstanceInformation", `{"MaxResults":50}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, body := request(tt.method, tt.url, tt.target, tt.body)
+			key := requestKey(req, body)
+
+			// the operation is only part of the readable prefix
+			same := key[strings.LastIndex(key, "-"):] == baseKey[strings.LastIndex(baseKey, "-"):]
+			if same != tt.same {
+				t.Errorf("requestKey() = %q, base %q, same %v, want %v", key, baseKey, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		target string
+		body   string
+		want   string
+	}{
+		{"json protocol", "https://ssm.us-east-1.amazonaws.com/", "AmazonSSM.GetParameter", `{}`, "GetParameter"},
+		{"query protocol", "https://ec2.us-east-1.amazonaws.com/", "", "Action=DescribeInstances&Version=2016-11-15", "DescribeInstances"},
+		{"plain http", "https://kcs.kineticcommerce.io/status/config", "", "", "/status/config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.target != "" {
+				req.Header.Set("X-Amz-Target", tt.target)
+			}
+
+			got := operation(req, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("operation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.WriteString(w, `{"Parameter":{"Name":"/dit/key","Type":"SecureString","Value":"hunter2"},"Call":`+string(rune('0'+calls))+`}`)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "recording")
+
+	recorder, err := NewRecorder(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player, err := NewPlayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(transport http.RoundTripper, body string) string {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/param", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	// the caller still sees the secret, the recording does not
+	if got := get(recorder, `{"Name":"/dit/key","Value":"new"}`); !strings.Contains(got, "hunter2") {
+		t.Errorf("recorded response = %s, want the live response", got)
+	}
+	get(recorder, `{"Name":"/dit/key","Value":"new"}`)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("recording directory mode = %v, want 0700", info.Mode().Perm())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("recorded %d files, want 2", len(files))
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("%s mode = %v, want 0600", file, info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "new") {
+			t.Errorf("%s holds a secret: %s", file, data)
+		}
+	}
+
+	// replays match the request whatever value it writes, in recording order
+	for i, want := range []string{`"Call":1`, `"Call":2`, `"Call":2`} {
+		got := get(player, `{"Name":"/dit/key","Value":"other"}`)
+		if !strings.Contains(got, want) || strings.Contains(got, "hunter2") {
+			t.Errorf("replay %d = %s, want %s redacted", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in recordings.
+const redacted = "REDACTED"
+
+// secretFields are JSON fields holding credentials or session tokens, such as
+// those returned by SSO, the instance metadata service and StartSession.
+var secretFields = map[string]bool{
+	"AccessKeyId":     true,
+	"SecretAccessKey": true,
+	"SessionToken":    true,
+	"Token":           true,
+	"TokenValue":      true,
+	"accessKeyId":     true,
+	"secretAccessKey": true,
+	"sessionToken":    true,
+	"accessToken":     true,
+	"refreshToken":    true,
+	"idToken":         true,
+	"clientSecret":    true,
+}
+
+// secretElements matches the XML elements of credentials in query protocol
+// responses.
+var secretElements = regexp.MustCompile(`<(AccessKeyId|SecretAccessKey|SessionToken)>[^<]*</`)
+
+// credentialHosts serve credentials. Requests to them are never recorded:
+// replays sign requests with dummy credentials and do not call them.
+var credentialHosts = []string{"sts.", "portal.sso.", "oidc.", "169.254.169.254", "169.254.170.2", "fd00:ec2::254"}
+
+// isCredentialRequest reports whether a request fetches credentials.
+func isCredentialRequest(req *http.Request) bool {
+	host := req.URL.Hostname()
+	for _, prefix := range credentialHosts {
+		if strings.HasPrefix(host, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scrubRequest redacts the secrets of a request body. Every parameter value
+// written, e.g. by PutParameter, is redacted, since the request may keep the
+// SecureString type of an existing parameter without naming it.
+func scrubRequest(body []byte) []byte {
+	return scrubJSON(body, func(object map[string]interface{}, field string) bool {
+		return secretFields[field] || field == "Value" && object["Name"] != nil
+	})
+}
+
+// scrubResponse redacts the secrets of a response body: credentials, session
+// tokens and the values of SecureString parameters.
+func scrubResponse(body []byte) []byte {
+	body = secretElements.ReplaceAll(body, []byte("<${1}>"+redacted+"</"))
+
+	return scrubJSON(body, func(object map[string]interface{}, field string) bool {
+		return secretFields[field] || field == "Value" && object["Type"] == "SecureString"
+	})
+}
+
+// scrubJSON replaces the string values of the fields secret reports, at any
+// depth. Bodies that are not JSON objects are returned unchanged, as are
+// bodies without secrets, so that their formatting is kept.
+func scrubJSON(body []byte, secret func(object map[string]interface{}, field string) bool) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return body
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&doc) != nil {
+		return body
+	}
+
+	if !scrubValue(doc, secret) {
+		return body
+	}
+
+	scrubbed, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+
+	return scrubbed
+}
+
+// scrubValue redacts the secrets in a decoded JSON value and reports whether
+// it changed anything.
+func scrubValue(value interface{}, secret func(object map[string]interface{}, field string) bool) bool {
+	changed := false
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for field, fieldValue := range v {
+			if _, ok := fieldValue.(string); ok && secret(v, field) {
+				v[field] = redacted
+				changed = true
+				continue
+			}
+			if scrubValue(fieldValue, secret) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if scrubValue(item, secret) {
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestScrubResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		secrets []string
+		kept    []string
+	}{
+		{
+			name:    "secure string parameter",
+			body:    `{"Parameter":{"Name":"/dit/db/password","Type":"SecureString","Value":"hunter2","Version":3}}`,
+			secrets: []string{"hunter2"},
+			kept:    []string{"/dit/db/password", `"Version":3`},
+		},
+		{
+			name:    "plain parameters",
+			body:    `{"Parameters":[{"Name":"/dit/host","Type":"String","Value":"db.internal"},{"Name":"/dit/key","Type":"SecureString","Value":"s3cret"}]}`,
+			secrets: []string{"s3cret"},
+			kept:    []string{"db.internal"},
+		},
+		{
+			name:    "sso credentials",
+			body:    `{"roleCredentials":{"accessKeyId":"ASIAXXX","secretAccessKey":"wJalr","sessionToken":"FwoG","expiration":1700000000000}}`,
+			secrets: []string{"ASIAXXX", "wJalr", "FwoG"},
+			kept:    []string{"1700000000000"},
+		},
+		{
+			name:    "session token",
+			body:    `{"SessionId":"kci-0123","StreamUrl":"wss://ssmmessages","TokenValue":"AAEAA"}`,
+			secrets: []string{"AAEAA"},
+			kept:    []string{"kci-0123"},
+		},
+		{
+			name:    "sts xml",
+			body:    `<Credentials><AccessKeyId>ASIAXXX</AccessKeyId><SecretAccessKey>wJalr</SecretAccessKey><SessionToken>FwoG</SessionToken></Credentials>`,
+			secrets: []string{"ASIAXXX", "wJalr", "FwoG"},
+			kept:    []string{"<SessionToken>REDACTED</SessionToken>"},
+		},
+		{
+			name: "nothing secret",
+			body: "{\n  \"Reservations\": []\n}",
+			kept: []string{"{\n  \"Reservations\": []\n}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(scrubResponse([]byte(tt.body)))
+
+			for _, secret := range tt.secrets {
+				if strings.Contains(got, secret) {
+					t.Errorf("scrubResponse() kept %q in %s", secret, got)
+				}
+			}
+			for _, kept := range tt.kept {
+				if !strings.Contains(got, kept) {
+					t.Errorf("scrubResponse() lost %q in %s", kept, got)
+				}
+			}
+		})
+	}
+}
+
+func TestScrubRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		secret string
+		kept   string
+	}{
+		{
+			name:   "put parameter without type",
+			body:   `{"Name":"/dit/db/password","Overwrite":true,"Value":"hunter2"}`,
+			secret: "hunter2",
+			kept:   "/dit/db/password",
+		},
+		{
+			name: "tag values",
+			body: `{"ResourceId":"i-1","Tags":[{"Key":"Name","Value":"web-1"}]}`,
+			kept: "web-1",
+		},
+		{
+			name: "query protocol",
+			body: "Action=DescribeInstances&Version=2016-11-15",
+			kept: "Action=DescribeInstances",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(scrubRequest([]byte(tt.body)))
+
+			if tt.secret != "" && strings.Contains(got, tt.secret) {
+				t.Errorf("scrubRequest() kept %q in %s", tt.secret, got)
+			}
+			if !strings.Contains(got, tt.kept) {
+				t.Errorf("scrubRequest() lost %q in %s", tt.kept, got)
+			}
+		})
+	}
+}
+
+func TestIsCredentialRequest(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://sts.us-east-1.amazonaws.com/", true},
+		{"https://portal.sso.us-east-1.amazonaws.com/federation/credentials", true},
+		{"https://oidc.us-east-1.amazonaws.com/token", true},
+		{"http://169.254.169.254/latest/api/token", true},
+		{"http://[fd00:ec2::254]/latest/meta-data/iam/security-credentials/", true},
+		{"https://ssm.us-east-1.amazonaws.com/", false},
+		{"https://kcs.kineticcommerce.io/status/config", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := isCredentialRequest(req)
+			if got != tt.want {
+				t.Errorf("isCredentialRequest(%s) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
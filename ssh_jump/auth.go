@@ -0,0 +1,307 @@
+package ssh_jump
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// Authentication methods, in the order they are tried by default.
+const (
+	AuthAgent    = "agent"
+	AuthKey      = "key"
+	AuthPassword = "password"
+)
+
+// DefaultAuthOrder tries the SSH agent first and then key files.
+var DefaultAuthOrder = []string{AuthAgent, AuthKey}
+
+// Auth provides the authentication methods for SSH connections. It is shared
+// by all connections of a run so that the agent is opened, and each key file
+// read and its passphrase asked for, at most once.
+//
+// Order lists the methods to use, see AuthAgent, AuthKey and AuthPassword.
+// IdentityFiles are tried before the IdentityFile entries of the SSH config;
+// when neither names a key, the ssh(1) default keys are tried. Prompt asks the
+// user for key passphrases and passwords; when nil, encrypted keys are skipped
+// and password authentication is unavailable.
+//
+// Close closes the connection to the agent once the Auth is done with; it is
+// opened again if the Auth is used afterwards.
+type Auth struct {
+	Order         []string
+	IdentityFiles []string
+	Prompt        func(prompt string) ([]byte, error)
+
+	mu          sync.Mutex
+	agentLoaded bool
+	agentConn   net.Conn
+	agentClient agent.ExtendedAgent
+	agentErr    error
+	keys        map[string]loadedKey
+}
+
+type loadedKey struct {
+	signer ssh.Signer
+	err    error
+}
+
+// NewAuth creates an Auth trying the methods in order, DefaultAuthOrder when
+// empty.
+func NewAuth(order []string, identityFiles []string) (*Auth, error) {
+	if len(order) == 0 {
+		order = DefaultAuthOrder
+	}
+
+	for _, method := range order {
+		switch method {
+		case AuthAgent, AuthKey, AuthPassword:
+		default:
+			return nil, fmt.Errorf("unknown authentication method %q, must be one of %s, %s or %s", method, AuthAgent, AuthKey, AuthPassword)
+		}
+	}
+
+	return &Auth{
+		Order:         order,
+		IdentityFiles: identityFiles,
+		keys:          map[string]loadedKey{},
+	}, nil
+}
+
+// TerminalPrompt reads a secret from the controlling terminal without echo. It
+// is suitable as Auth.Prompt and fails when there is no terminal, e.g. on CI.
+func TerminalPrompt(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to prompt on: %w", err)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	secret, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+
+	return secret, err
+}
+
+// DefaultIdentityFiles returns the keys ssh(1) tries when none are configured.
+func DefaultIdentityFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	return []string{
+		filepath.Join(home, ".ssh", "id_ed25519"),
+		filepath.Join(home, ".ssh", "id_ecdsa"),
+		filepath.Join(home, ".ssh", "id_rsa"),
+	}
+}
+
+// AuthAttempts records what was tried while authenticating to one endpoint, to
+// explain an authentication failure.
+type AuthAttempts struct {
+	notes []string
+}
+
+func (attempts *AuthAttempts) add(format string, args ...interface{}) {
+	attempts.notes = append(attempts.notes, fmt.Sprintf(format, args...))
+}
+
+// String lists the attempts, e.g. "agent: SSH_AUTH_SOCK not set; key
+// ~/.ssh/id_rsa: offered".
+func (attempts *AuthAttempts) String() string {
+	if len(attempts.notes) == 0 {
+		return "no authentication methods were available"
+	}
+
+	return strings.Join(attempts.notes, "; ")
+}
+
+// Methods returns the ssh.AuthMethods for an endpoint. Agent and key signers
+// are combined into one public key method, in the configured order, since the
+// SSH client tries each kind of method only once. The returned AuthAttempts is
+// filled in while the connection authenticates.
+func (a *Auth) Methods(ep Endpoint) ([]ssh.AuthMethod, *AuthAttempts) {
+	attempts := &AuthAttempts{}
+
+	var methods []ssh.AuthMethod
+	publicKeys := false
+
+	for _, method := range a.Order {
+		switch method {
+		case AuthAgent, AuthKey:
+			if publicKeys {
+				continue
+			}
+			publicKeys = true
+
+			methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+				return a.signers(ep, attempts), nil
+			}))
+		case AuthPassword:
+			methods = append(methods, ssh.PasswordCallback(func() (string, error) {
+				if a.Prompt == nil {
+					attempts.add("password: no terminal to prompt on")
+					return "", errors.New("no terminal to prompt for a password")
+				}
+
+				attempts.add("password: prompted")
+				password, err := a.Prompt(fmt.Sprintf("%s@%s's password: ", ep.User, ep.Host))
+
+				return string(password), err
+			}))
+		}
+	}
+
+	return methods, attempts
+}
+
+// signers returns the agent and key signers in the configured order.
+func (a *Auth) signers(ep Endpoint, attempts *AuthAttempts) []ssh.Signer {
+	var signers []ssh.Signer
+
+	for _, method := range a.Order {
+		switch method {
+		case AuthAgent:
+			signers = append(signers, a.agentSigners(attempts)...)
+		case AuthKey:
+			for _, file := range a.identityFiles(ep) {
+				signer, err := a.loadKey(file)
+				if err != nil {
+					attempts.add("key %s: %v", file, err)
+					continue
+				}
+
+				attempts.add("key %s: offered", file)
+				signers = append(signers, signer)
+			}
+		}
+	}
+
+	return signers
+}
+
+func (a *Auth) agentSigners(attempts *AuthAttempts) []ssh.Signer {
+	a.mu.Lock()
+	if !a.agentLoaded {
+		a.agentLoaded = true
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			a.agentErr = errors.New("SSH_AUTH_SOCK not set")
+		} else {
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				a.agentErr = fmt.Errorf("failed to open SSH_AUTH_SOCK: %w", err)
+			} else {
+				a.agentConn = conn
+				a.agentClient = agent.NewClient(conn)
+			}
+		}
+	}
+	client, err := a.agentClient, a.agentErr
+	a.mu.Unlock()
+
+	if err != nil {
+		attempts.add("agent: %v", err)
+		return nil
+	}
+
+	signers, err := client.Signers()
+	if err != nil {
+		attempts.add("agent: %v", err)
+		return nil
+	}
+
+	attempts.add("agent: offered %d keys", len(signers))
+
+	return signers
+}
+
+// Close closes the connection to the agent, if one was opened.
+func (a *Auth) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.agentConn != nil {
+		a.agentConn.Close()
+	}
+	a.agentLoaded = false
+	a.agentConn = nil
+	a.agentClient = nil
+	a.agentErr = nil
+}
+
+// identityFiles returns the explicit key files followed by those of the SSH
+// config, or the ssh(1) defaults when there are none.
+func (a *Auth) identityFiles(ep Endpoint) []string {
+	files := append(append([]string{}, a.IdentityFiles...), ep.IdentityFiles...)
+	if len(files) > 0 {
+		return files
+	}
+
+	var defaults []string
+	for _, file := range DefaultIdentityFiles() {
+		if _, err := os.Stat(file); err == nil {
+			defaults = append(defaults, file)
+		}
+	}
+
+	return defaults
+}
+
+// loadKey reads a private key once, asking for its passphrase if needed.
+func (a *Auth) loadKey(file string) (ssh.Signer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if key, ok := a.keys[file]; ok {
+		return key.signer, key.err
+	}
+
+	signer, err := a.parseKey(file)
+	a.keys[file] = loadedKey{signer: signer, err: err}
+
+	return signer, err
+}
+
+func (a *Auth) parseKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+
+	if a.Prompt == nil {
+		return nil, errors.New("encrypted and no terminal to prompt for the passphrase")
+	}
+
+	passphrase, err := a.Prompt(fmt.Sprintf("Enter passphrase for key '%s': ", file))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passphrase: %w", err)
+	}
+
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt: %w", err)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,309 @@
+package ssh_jump
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// writeTestKey writes a new ed25519 key to dir, encrypted when passphrase is
+// set, and returns its public key and file name.
+func writeTestKey(t *testing.T, dir string, name string, passphrase string) (ssh.PublicKey, string) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(private, name)
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(private, name, []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, name)
+	err = os.WriteFile(file, pem.EncodeToMemory(block), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, file
+}
+
+// startTestAgent serves an agent holding a new key on a socket in a temporary
+// directory and points SSH_AUTH_SOCK at it. It returns the agent's key.
+func startTestAgent(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := agent.NewKeyring()
+	err = keyring.Add(agent.AddedKey{PrivateKey: private})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer.PublicKey()
+}
+
+func TestNewAuth(t *testing.T) {
+	tests := []struct {
+		order   []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, DefaultAuthOrder, false},
+		{[]string{AuthKey, AuthAgent}, []string{AuthKey, AuthAgent}, false},
+		{[]string{AuthPassword}, []string{AuthPassword}, false},
+		{[]string{AuthAgent, "kerberos"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.order, ","), func(t *testing.T) {
+			auth, err := NewAuth(tt.order, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAuth(%q) error = %v, want error %v", tt.order, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(auth.Order, tt.want) {
+				t.Errorf("NewAuth(%q).Order = %q, want %q", tt.order, auth.Order, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthSigners(t *testing.T) {
+	dir := t.TempDir()
+	fileKey, file := writeTestKey(t, dir, "id_ed25519", "")
+	encryptedKey, encrypted := writeTestKey(t, dir, "id_encrypted", "secret")
+	missing := filepath.Join(dir, "id_missing")
+
+	prompt := func(string) ([]byte, error) { return []byte("secret"), nil }
+	noTerminal := func(string) ([]byte, error) { return nil, errors.New("no terminal") }
+
+	const agentKey = "agent"
+
+	tests := []struct {
+		name          string
+		order         []string
+		noAgent       bool
+		identityFiles []string
+		prompt        func(string) ([]byte, error)
+		// the keys offered, agentKey for the agent's
+		want         []interface{}
+		wantAttempts string
+	}{
+		{
+			name:          "agent first",
+			order:         []string{AuthAgent, AuthKey},
+			identityFiles: []string{file},
+			want:          []interface{}{agentKey, fileKey},
+			wantAttempts:  "agent: offered 1 keys; key " + file + ": offered",
+		},
+		{
+			name:          "key first",
+			order:         []string{AuthKey, AuthAgent},
+			identityFiles: []string{file},
+			want:          []interface{}{fileKey, agentKey},
+			wantAttempts:  "key " + file + ": offered; agent: offered 1 keys",
+		},
+		{
+			name:          "no agent",
+			order:         []string{AuthAgent, AuthKey},
+			noAgent:       true,
+			identityFiles: []string{file},
+			want:          []interface{}{fileKey},
+			wantAttempts:  "agent: SSH_AUTH_SOCK not set; key " + file + ": offered",
+		},
+		{
+			name:          "missing key",
+			order:         []string{AuthKey},
+			identityFiles: []string{missing, file},
+			want:          []interface{}{fileKey},
+			wantAttempts:  "key " + missing + ": not found; key " + file + ": offered",
+		},
+		{
+			name:          "encrypted key without a prompt",
+			order:         []string{AuthKey},
+			identityFiles: []string{encrypted},
+			wantAttempts:  "key " + encrypted + ": encrypted and no terminal to prompt for the passphrase",
+		},
+		{
+			name:          "encrypted key with a failing prompt",
+			order:         []string{AuthKey},
+			identityFiles: []string{encrypted},
+			prompt:        noTerminal,
+			wantAttempts:  "key " + encrypted + ": unable to read passphrase: no terminal",
+		},
+		{
+			name:          "encrypted key",
+			order:         []string{AuthKey},
+			identityFiles: []string{encrypted},
+			prompt:        prompt,
+			want:          []interface{}{encryptedKey},
+			wantAttempts:  "key " + encrypted + ": offered",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentPublic := startTestAgent(t)
+			if tt.noAgent {
+				t.Setenv("SSH_AUTH_SOCK", "")
+			}
+
+			auth, err := NewAuth(tt.order, tt.identityFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth.Prompt = tt.prompt
+			defer auth.Close()
+
+			attempts := &AuthAttempts{}
+			signers := auth.signers(Endpoint{Host: "bastion"}, attempts)
+
+			var want []string
+			for _, key := range tt.want {
+				if key == agentKey {
+					key = agentPublic
+				}
+				want = append(want, ssh.FingerprintSHA256(key.(ssh.PublicKey)))
+			}
+			var got []string
+			for _, signer := range signers {
+				got = append(got, ssh.FingerprintSHA256(signer.PublicKey()))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("signers = %q, want %q", got, want)
+			}
+
+			if attempts.String() != tt.wantAttempts {
+				t.Errorf("attempts = %q, want %q", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestAuthCloseReopensAgent(t *testing.T) {
+	startTestAgent(t)
+
+	auth, err := NewAuth([]string{AuthAgent}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		attempts := &AuthAttempts{}
+		if signers := auth.signers(Endpoint{}, attempts); len(signers) != 1 {
+			t.Errorf("use %d: got %d signers (%s)", i, len(signers), attempts)
+		}
+		auth.Close()
+	}
+}
+
+func TestDialAuthentication(t *testing.T) {
+	dir := t.TempDir()
+	authorized, file := writeTestKey(t, dir, "id_authorized", "")
+	_, other := writeTestKey(t, dir, "id_other", "")
+
+	server := newTestServer(t, authorized)
+	host, port := server.addr()
+
+	hostKeys := newTestHostKeys(t, false, knownhosts.Line([]string{knownhosts.Normalize(host + ":" + port)}, server.hostKey.PublicKey()))
+	ep := Endpoint{Alias: "bastion", Host: host, Port: port, User: "ops"}
+
+	tests := []struct {
+		name          string
+		order         []string
+		identityFiles []string
+		wantErr       string
+	}{
+		{
+			name:          "authorized key",
+			order:         []string{AuthAgent, AuthKey},
+			identityFiles: []string{other, file},
+		},
+		{
+			name:          "no authorized key",
+			order:         []string{AuthAgent, AuthKey},
+			identityFiles: []string{other},
+			wantErr:       "(as ops; tried agent: SSH_AUTH_SOCK not set; key " + other + ": offered)",
+		},
+		{
+			name:    "no methods",
+			order:   []string{AuthKey},
+			wantErr: "tried no authentication methods were available",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SSH_AUTH_SOCK", "")
+			t.Setenv("HOME", t.TempDir())
+
+			auth, err := NewAuth(tt.order, tt.identityFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client, err := dial(nil, ep, auth, hostKeys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("dial() error = %v", err)
+				}
+				client.Close()
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("dial() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
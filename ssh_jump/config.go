@@ -0,0 +1,172 @@
+package ssh_jump
+
+import (
+	"fmt"
+	"net"
+	"os"
+	osuser "os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// maxProxyJumps limits how deep ProxyJump chains are followed, guarding
+// against loops in the SSH config.
+const maxProxyJumps = 8
+
+// sshConfig is the part of ssh_config.UserSettings that resolve uses.
+type sshConfig interface {
+	Get(alias, key string) string
+	GetAll(alias, key string) []string
+	GetStrict(alias, key string) (string, error)
+}
+
+// userSettings reads ~/.ssh/config and /etc/ssh/ssh_config.
+var userSettings sshConfig = ssh_config.DefaultUserSettings
+
+// Endpoint is an SSH destination resolved through the SSH config. Alias is the
+// name it was given as, which may be a Host alias from the config; Host is the
+// actual host name to connect to. Auth, when set, is used instead of the
+// connection's default Auth.
+type Endpoint struct {
+	Alias         string
+	Host          string
+	Port          string
+	User          string
+	IdentityFiles []string
+	ProxyJump     []Endpoint
+	Auth          *Auth
+}
+
+// Address returns the host:port to dial.
+func (ep Endpoint) Address() string {
+	return net.JoinHostPort(ep.Host, ep.Port)
+}
+
+// Resolve looks up a destination in the SSH config the way ssh(1) does, so
+// that Host aliases, HostName, User, Port, IdentityFile and ProxyJump all
+// apply. destination may be given as [user@]host[:port]; an explicit user or
+// port wins over the config. fallbackUser is used when neither the
+// destination nor the config names a user.
+func Resolve(destination string, fallbackUser string) (Endpoint, error) {
+	return resolve(destination, fallbackUser, 0)
+}
+
+func resolve(destination string, fallbackUser string, depth int) (Endpoint, error) {
+	if depth > maxProxyJumps {
+		return Endpoint{}, fmt.Errorf("ProxyJump chain for %s is too long", destination)
+	}
+
+	user, alias, port := splitDestination(destination)
+	if alias == "" {
+		return Endpoint{}, fmt.Errorf("invalid SSH destination %q", destination)
+	}
+
+	ep := Endpoint{Alias: alias}
+
+	hostName, err := userSettings.GetStrict(alias, "HostName")
+	if err != nil {
+		return ep, fmt.Errorf("unable to read SSH config: %w", err)
+	}
+	ep.Host = alias
+	if hostName != "" {
+		ep.Host = strings.ReplaceAll(hostName, "%h", alias)
+	}
+
+	ep.Port = port
+	if ep.Port == "" {
+		ep.Port = userSettings.Get(alias, "Port")
+	}
+	if ep.Port == "" {
+		ep.Port = "22"
+	}
+
+	ep.User = user
+	if ep.User == "" {
+		ep.User = userSettings.Get(alias, "User")
+	}
+	if ep.User == "" {
+		ep.User = fallbackUser
+	}
+
+	for _, file := range userSettings.GetAll(alias, "IdentityFile") {
+		// the library reports the ssh(1) default when nothing is configured
+		if file == ssh_config.Default("IdentityFile") {
+			continue
+		}
+		ep.IdentityFiles = append(ep.IdentityFiles, expandPath(file, ep))
+	}
+
+	proxyJump := userSettings.Get(alias, "ProxyJump")
+	if proxyJump != "" && proxyJump != "none" {
+		for _, hop := range strings.Split(proxyJump, ",") {
+			hopEndpoint, err := resolve(strings.TrimSpace(hop), localUser(), depth+1)
+			if err != nil {
+				return ep, err
+			}
+			ep.ProxyJump = append(ep.ProxyJump, hopEndpoint)
+		}
+	}
+
+	return ep, nil
+}
+
+// Route returns the endpoints to pass through, in order, to reach ep: the
+// ProxyJump hosts of ep (each preceded by its own ProxyJump hosts) followed by
+// ep itself.
+func (ep Endpoint) Route() []Endpoint {
+	var route []Endpoint
+	for _, hop := range ep.ProxyJump {
+		route = append(route, hop.Route()...)
+	}
+
+	return append(route, ep)
+}
+
+// withUser prefixes destination with user@ unless user is empty or the
+// destination already names a user.
+func withUser(destination string, user string) string {
+	if user == "" || strings.Contains(destination, "@") {
+		return destination
+	}
+
+	return user + "@" + destination
+}
+
+// localUser returns the name of the user running kci, the ssh(1) default.
+func localUser() string {
+	current, err := osuser.Current()
+	if err != nil {
+		return ""
+	}
+
+	return current.Username
+}
+
+// splitDestination splits [user@]host[:port].
+func splitDestination(destination string) (user string, host string, port string) {
+	host = destination
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		user, host = host[:i], host[i+1:]
+	}
+
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+
+	return user, host, port
+}
+
+// expandPath expands ~ and the %h, %r and %p tokens of an IdentityFile.
+func expandPath(path string, ep Endpoint) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+
+	replacer := strings.NewReplacer("%h", ep.Host, "%r", ep.User, "%p", ep.Port, "%%", "%")
+
+	return replacer.Replace(path)
+}
@@ -0,0 +1,257 @@
+package ssh_jump
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+// testConfig serves an SSH config from memory with the defaults that
+// ssh_config.UserSettings applies.
+type testConfig struct {
+	config *ssh_config.Config
+}
+
+func (c testConfig) Get(alias, key string) string {
+	value, _ := c.GetStrict(alias, key)
+	return value
+}
+
+func (c testConfig) GetAll(alias, key string) []string {
+	values, _ := c.config.GetAll(alias, key)
+	if len(values) == 0 && ssh_config.Default(key) != "" {
+		return []string{ssh_config.Default(key)}
+	}
+	return values
+}
+
+func (c testConfig) GetStrict(alias, key string) (string, error) {
+	value, err := c.config.Get(alias, key)
+	if value == "" {
+		return ssh_config.Default(key), err
+	}
+	return value, err
+}
+
+// useTestConfig makes resolve read config instead of the user's SSH config
+// until the test ends.
+func useTestConfig(t *testing.T, config string) {
+	t.Helper()
+
+	decoded, err := ssh_config.DecodeBytes([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := userSettings
+	userSettings = testConfig{config: decoded}
+	t.Cleanup(func() { userSettings = previous })
+}
+
+func TestResolve(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	useTestConfig(t, `
+Host bastion
+  HostName bastion.example.com
+  User ops
+  Port 2222
+  IdentityFile ~/.ssh/bastion
+  IdentityFile /keys/%r@%h:%p
+
+Host *.internal
+  HostName %h.example.com
+  ProxyJump bastion
+
+Host deep
+  User deploy
+  ProxyJump inner
+
+Host inner
+  User ops
+  ProxyJump bastion, other@edge:2200
+
+Host direct
+  ProxyJump none
+
+Host loop-a
+  ProxyJump loop-b
+
+Host loop-b
+  ProxyJump loop-a
+`)
+
+	tests := []struct {
+		name        string
+		destination string
+		want        Endpoint
+		// Alias of each endpoint of the route, ep.Route()
+		wantRoute []string
+		wantErr   string
+	}{
+		{
+			name:        "plain host",
+			destination: "web-1",
+			want:        Endpoint{Alias: "web-1", Host: "web-1", Port: "22", User: "fallback"},
+			wantRoute:   []string{"web-1"},
+		},
+		{
+			name:        "config alias",
+			destination: "bastion",
+			want: Endpoint{
+				Alias:         "bastion",
+				Host:          "bastion.example.com",
+				Port:          "2222",
+				User:          "ops",
+				IdentityFiles: []string{filepath.Join(home, ".ssh", "bastion"), "/keys/ops@bastion.example.com:2222"},
+			},
+			wantRoute: []string{"bastion"},
+		},
+		{
+			name:        "explicit user and port win",
+			destination: "root@bastion:22",
+			want: Endpoint{
+				Alias:         "bastion",
+				Host:          "bastion.example.com",
+				Port:          "22",
+				User:          "root",
+				IdentityFiles: []string{filepath.Join(home, ".ssh", "bastion"), "/keys/root@bastion.example.com:22"},
+			},
+			wantRoute: []string{"bastion"},
+		},
+		{
+			name:        "host name token and proxy jump",
+			destination: "db.internal",
+			want:        Endpoint{Alias: "db.internal", Host: "db.internal.example.com", Port: "22", User: "fallback"},
+			wantRoute:   []string{"bastion", "db.internal"},
+		},
+		{
+			name:        "nested proxy jumps",
+			destination: "deep",
+			want:        Endpoint{Alias: "deep", Host: "deep", Port: "22", User: "deploy"},
+			wantRoute:   []string{"bastion", "edge", "inner", "deep"},
+		},
+		{
+			name:        "proxy jump none",
+			destination: "direct",
+			want:        Endpoint{Alias: "direct", Host: "direct", Port: "22", User: "fallback"},
+			wantRoute:   []string{"direct"},
+		},
+		{
+			name:        "proxy jump loop",
+			destination: "loop-a",
+			wantErr:     "too long",
+		},
+		{
+			name:        "no host",
+			destination: "ops@",
+			wantErr:     "invalid SSH destination",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ep, err := Resolve(tt.destination, "fallback")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %q", tt.destination, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) error = %v", tt.destination, err)
+			}
+
+			var route []string
+			for _, hop := range ep.Route() {
+				route = append(route, hop.Alias)
+			}
+			if !reflect.DeepEqual(route, tt.wantRoute) {
+				t.Errorf("Resolve(%q).Route() = %q, want %q", tt.destination, route, tt.wantRoute)
+			}
+
+			ep.ProxyJump = nil
+			if !reflect.DeepEqual(ep, tt.want) {
+				t.Errorf("Resolve(%q) = %+v, want %+v", tt.destination, ep, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveProxyJumpUsers(t *testing.T) {
+	useTestConfig(t, `
+Host inner
+  ProxyJump bastion, other@edge:2200
+
+Host bastion
+  User ops
+`)
+
+	ep, err := Resolve("inner", "fallback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// hops use the config or the local user, as ssh(1) does, never the fallback
+	var got []string
+	for _, hop := range ep.ProxyJump {
+		got = append(got, hop.User+"@"+hop.Address())
+	}
+	want := []string{"ops@bastion:22", "other@edge:2200"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ProxyJump = %q, want %q", got, want)
+	}
+}
+
+func TestSplitDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		user        string
+		host        string
+		port        string
+	}{
+		{"bastion", "", "bastion", ""},
+		{"ops@bastion", "ops", "bastion", ""},
+		{"ops@bastion:2222", "ops", "bastion", "2222"},
+		{"ops@[::1]:2222", "ops", "::1", "2222"},
+		{"a@b@bastion", "a@b", "bastion", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.destination, func(t *testing.T) {
+			user, host, port := splitDestination(tt.destination)
+			if user != tt.user || host != tt.host || port != tt.port {
+				t.Errorf("splitDestination(%q) = %q, %q, %q, want %q, %q, %q", tt.destination, user, host, port, tt.user, tt.host, tt.port)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	ep := Endpoint{Host: "bastion.example.com", Port: "2222", User: "ops"}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/keys/id_ed25519", "/keys/id_ed25519"},
+		{"~/.ssh/id_rsa", filepath.Join(home, ".ssh", "id_rsa")},
+		{"~/.ssh/%r@%h-%p", filepath.Join(home, ".ssh", "ops@bastion.example.com-2222")},
+		{"/keys/100%%", "/keys/100%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := expandPath(tt.path, ep); got != tt.want {
+				t.Errorf("expandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
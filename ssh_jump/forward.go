@@ -0,0 +1,186 @@
+package ssh_jump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Forward is a local port forwarded to a host behind the jump hosts, as with
+// ssh -L. Host is the target as given, which may be a name still to be
+// resolved by the caller, and Port may be empty when the target implies one.
+type Forward struct {
+	Bind  string
+	Local string
+	Host  string
+	Port  string
+}
+
+// ParseForward parses [bind_address:]port:host[:hostport]. With three fields
+// the first is taken as the bind address unless it is a port number. The bind
+// address defaults to localhost.
+func ParseForward(spec string) (Forward, error) {
+	fields := strings.Split(spec, ":")
+
+	var fwd Forward
+	switch len(fields) {
+	case 2:
+		fwd = Forward{Local: fields[0], Host: fields[1]}
+	case 3:
+		if isPort(fields[0]) {
+			fwd = Forward{Local: fields[0], Host: fields[1], Port: fields[2]}
+		} else {
+			fwd = Forward{Bind: fields[0], Local: fields[1], Host: fields[2]}
+		}
+	case 4:
+		fwd = Forward{Bind: fields[0], Local: fields[1], Host: fields[2], Port: fields[3]}
+	default:
+		return fwd, fmt.Errorf("invalid forward %q, must be [bind_address:]port:host[:hostport]", spec)
+	}
+
+	if fwd.Bind == "" {
+		fwd.Bind = "localhost"
+	}
+
+	if !isPort(fwd.Local) || fwd.Host == "" || (fwd.Port != "" && !isPort(fwd.Port)) {
+		return fwd, fmt.Errorf("invalid forward %q, must be [bind_address:]port:host[:hostport]", spec)
+	}
+
+	return fwd, nil
+}
+
+// LocalAddress returns the address to listen on.
+func (fwd Forward) LocalAddress() string {
+	return net.JoinHostPort(fwd.Bind, fwd.Local)
+}
+
+// RemoteAddress returns the address to connect to from the last jump host.
+func (fwd Forward) RemoteAddress() string {
+	return net.JoinHostPort(fwd.Host, fwd.Port)
+}
+
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 0 && port <= 65535
+}
+
+// Forward accepts connections on listener and forwards each to remote through
+// the jump hosts until ctx is done or accepting fails. It then closes the
+// listener and the open connections and returns once they are all closed.
+// Failures of a single connection are logged and do not stop the forward.
+func (p *Pool) Forward(ctx context.Context, listener net.Listener, remote string) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	closing := false
+	open := map[net.Conn]bool{}
+
+	// track registers a connection to close on shutdown, and reports false
+	// once shutdown began
+	track := func(conn net.Conn) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closing {
+			return false
+		}
+		open[conn] = true
+
+		return true
+	}
+	untrack := func(conn net.Conn) {
+		mu.Lock()
+		delete(open, conn)
+		mu.Unlock()
+		conn.Close()
+	}
+	closeAll := func() {
+		listener.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		closing = true
+		for conn := range open {
+			conn.Close()
+		}
+	}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeAll()
+		case <-stopped:
+		}
+	}()
+
+	var err error
+	for {
+		var local net.Conn
+		local, err = listener.Accept()
+		if err != nil {
+			break
+		}
+
+		if !track(local) {
+			local.Close()
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer untrack(local)
+
+			remoteConn, err := p.DialContext(ctx, "tcp", remote)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("forward %s: %v", listener.Addr(), err)
+				}
+				return
+			}
+
+			if !track(remoteConn) {
+				remoteConn.Close()
+				return
+			}
+			defer untrack(remoteConn)
+
+			pipe(local, remoteConn)
+		}()
+	}
+
+	// a failed accept leaves the forwarded connections to be closed
+	closeAll()
+	wg.Wait()
+
+	if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return fmt.Errorf("forward %s stopped: %w", listener.Addr(), err)
+}
+
+// pipe copies between two connections until either side is done.
+func pipe(a net.Conn, b net.Conn) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+
+	<-done
+}
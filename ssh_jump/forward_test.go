@@ -0,0 +1,46 @@
+package ssh_jump
+
+import "testing"
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Forward
+		wantErr bool
+	}{
+		{"5432:orders-db", Forward{Bind: "localhost", Local: "5432", Host: "orders-db"}, false},
+		{"8080:web-1:80", Forward{Bind: "localhost", Local: "8080", Host: "web-1", Port: "80"}, false},
+		{"0.0.0.0:5432:orders-db", Forward{Bind: "0.0.0.0", Local: "5432", Host: "orders-db"}, false},
+		{"0.0.0.0:8080:web.prod:80", Forward{Bind: "0.0.0.0", Local: "8080", Host: "web.prod", Port: "80"}, false},
+		{":8080:web-1:80", Forward{Bind: "localhost", Local: "8080", Host: "web-1", Port: "80"}, false},
+		{"orders-db", Forward{}, true},
+		{"a:b:c:d:e", Forward{}, true},
+		{"http:orders-db", Forward{}, true},
+		{"5432:", Forward{}, true},
+		{"8080:web-1:http", Forward{}, true},
+		{"70000:web-1:80", Forward{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseForward(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseForward(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseForward(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardAddresses(t *testing.T) {
+	fwd := Forward{Bind: "::1", Local: "5432", Host: "10.0.0.1", Port: "5432"}
+
+	if got := fwd.LocalAddress(); got != "[::1]:5432" {
+		t.Errorf("LocalAddress() = %q, want [::1]:5432", got)
+	}
+	if got := fwd.RemoteAddress(); got != "10.0.0.1:5432" {
+		t.Errorf("RemoteAddress() = %q, want 10.0.0.1:5432", got)
+	}
+}
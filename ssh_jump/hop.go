@@ -0,0 +1,61 @@
+package ssh_jump
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hop is one host on the way to a target. Host is an SSH config alias or a
+// [user@]host[:port] destination. User and Port, when set, win over both the
+// destination and the SSH config. Auth, when set, replaces the SSHJump's Auth
+// for this hop only.
+type Hop struct {
+	Host string
+	User string
+	Port string
+	Auth *Auth
+}
+
+// ParseChain parses a comma separated list of jump hosts in ProxyJump form,
+// e.g. "ops@bastion-a,bastion-b:2222". defaultUser applies to hops that do not
+// name a user; the SSH config is consulted when it is empty too.
+func ParseChain(spec string, defaultUser string) ([]Hop, error) {
+	var hops []Hop
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			if strings.TrimSpace(spec) == "" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid jump chain %q: empty hop", spec)
+		}
+
+		hops = append(hops, Hop{Host: withUser(part, defaultUser)})
+	}
+
+	return hops, nil
+}
+
+// resolve looks the hop up in the SSH config.
+func (hop Hop) resolve(fallbackUser string) (Endpoint, error) {
+	ep, err := Resolve(withUser(hop.Host, hop.User), fallbackUser)
+	if err != nil {
+		return ep, err
+	}
+
+	if hop.User != "" {
+		ep.User = hop.User
+	}
+	if hop.Port != "" {
+		ep.Port = hop.Port
+	}
+	ep.Auth = hop.Auth
+
+	return ep, nil
+}
+
+// String returns the chain in ProxyJump form.
+func (hop Hop) String() string {
+	return withUser(hop.Host, hop.User)
+}
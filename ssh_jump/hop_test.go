@@ -0,0 +1,40 @@
+package ssh_jump
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseChain(t *testing.T) {
+	tests := []struct {
+		spec        string
+		defaultUser string
+		want        []string
+		wantErr     bool
+	}{
+		{"", "", nil, false},
+		{"  ", "ops", nil, false},
+		{"bastion", "", []string{"bastion"}, false},
+		{"bastion", "ops", []string{"ops@bastion"}, false},
+		{"ops@bastion-a, bastion-b:2222", "ec2-user", []string{"ops@bastion-a", "ec2-user@bastion-b:2222"}, false},
+		{"bastion-a,,bastion-b", "", nil, true},
+		{"bastion-a,", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			hops, err := ParseChain(tt.spec, tt.defaultUser)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChain(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			}
+
+			var got []string
+			for _, hop := range hops {
+				got = append(got, hop.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseChain(%q) = %q, want %q", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
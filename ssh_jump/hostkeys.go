@@ -0,0 +1,199 @@
+package ssh_jump
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeys verifies SSH host keys against known_hosts files. Keys are checked
+// against the user's ~/.ssh/known_hosts and the kci managed known_hosts. A host
+// whose key does not match a known key is always rejected. An unknown host is
+// rejected unless AcceptNew is set, in which case its key is added to the
+// managed file (trust on first use).
+type HostKeys struct {
+	Files       []string
+	ManagedFile string
+	AcceptNew   bool
+
+	mu       sync.Mutex
+	callback ssh.HostKeyCallback
+}
+
+// DefaultKnownHostsFiles returns ~/.ssh/known_hosts and the kci managed
+// ~/.config/kci/known_hosts, the latter last.
+func DefaultKnownHostsFiles() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find home directory: %w", err)
+	}
+
+	return []string{
+		filepath.Join(home, ".ssh", "known_hosts"),
+		filepath.Join(home, ".config", "kci", "known_hosts"),
+	}, nil
+}
+
+// NewHostKeys creates a HostKeys using the default known_hosts files.
+func NewHostKeys(acceptNew bool) (*HostKeys, error) {
+	files, err := DefaultKnownHostsFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	hk := &HostKeys{
+		Files:       files,
+		ManagedFile: files[len(files)-1],
+		AcceptNew:   acceptNew,
+	}
+
+	err = hk.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return hk, nil
+}
+
+// load (re)reads the known_hosts files that exist.
+func (hk *HostKeys) load() error {
+	var existing []string
+	for _, file := range hk.Files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+
+	callback, err := knownhosts.New(existing...)
+	if err != nil {
+		return fmt.Errorf("unable to read known hosts: %w", err)
+	}
+
+	hk.callback = callback
+
+	return nil
+}
+
+// Callback returns an ssh.HostKeyCallback that verifies keys.
+func (hk *HostKeys) Callback() ssh.HostKeyCallback {
+	return hk.verify
+}
+
+func (hk *HostKeys) verify(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	hk.mu.Lock()
+	defer hk.mu.Unlock()
+
+	err := hk.callback(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return fmt.Errorf("host key for %s rejected: %w", hostname, err)
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+
+	if len(keyErr.Want) > 0 {
+		known := make([]string, len(keyErr.Want))
+		for i, want := range keyErr.Want {
+			known[i] = fmt.Sprintf("%s:%d", want.Filename, want.Line)
+		}
+
+		return fmt.Errorf("HOST KEY MISMATCH for %s: it presented %s key %s which does not match the key recorded at %s. "+
+			"Someone could be intercepting the connection. If the host was rebuilt, remove the old entry and try again",
+			hostname, key.Type(), fingerprint, strings.Join(known, ", "))
+	}
+
+	if !hk.AcceptNew {
+		return fmt.Errorf("unknown host key for %s (%s %s): verify the fingerprint and rerun with --accept-new to trust it",
+			hostname, key.Type(), fingerprint)
+	}
+
+	err = hk.add(hostname, remote, key)
+	if err != nil {
+		return err
+	}
+	log.Printf("Permanently added %s (%s %s) to %s", hostname, key.Type(), fingerprint, hk.ManagedFile)
+
+	return nil
+}
+
+// add appends a host key to the managed known_hosts file and reloads.
+func (hk *HostKeys) add(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if remote != nil && knownhosts.Normalize(remote.String()) != addresses[0] {
+		addresses = append(addresses, knownhosts.Normalize(remote.String()))
+	}
+
+	err := os.MkdirAll(filepath.Dir(hk.ManagedFile), 0o700)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(hk.ManagedFile), err)
+	}
+
+	file, err := os.OpenFile(hk.ManagedFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", hk.ManagedFile, err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, knownhosts.Line(addresses, key))
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", hk.ManagedFile, err)
+	}
+
+	return hk.load()
+}
+
+// Algorithms returns the host key algorithms matching the keys known for
+// address, so that the server presents a key that can be verified rather than
+// its preferred one. It returns nil for unknown hosts.
+func (hk *HostKeys) Algorithms(address string) []string {
+	hk.mu.Lock()
+	defer hk.mu.Unlock()
+
+	// Checking a key that cannot match lists the known keys for the host.
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	err = hk.callback(address, &net.TCPAddr{IP: net.IPv4zero}, probe)
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, want := range keyErr.Want {
+		for _, algorithm := range keyAlgorithms(want.Key.Type()) {
+			if !seen[algorithm] {
+				seen[algorithm] = true
+				algorithms = append(algorithms, algorithm)
+			}
+		}
+	}
+
+	return algorithms
+}
+
+// keyAlgorithms maps a key type to the signature algorithms that use it.
+func keyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+
+	return []string{keyType}
+}
@@ -0,0 +1,139 @@
+package ssh_jump
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestHostKeys returns a HostKeys reading a known_hosts file with the given
+// lines and a managed file, both in a temporary directory.
+func newTestHostKeys(t *testing.T, acceptNew bool, lines ...string) *HostKeys {
+	t.Helper()
+
+	dir := t.TempDir()
+	known := filepath.Join(dir, "known_hosts")
+	err := os.WriteFile(known, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hk := &HostKeys{
+		Files:       []string{known, filepath.Join(dir, "kci", "known_hosts")},
+		ManagedFile: filepath.Join(dir, "kci", "known_hosts"),
+		AcceptNew:   acceptNew,
+	}
+	err = hk.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hk
+}
+
+func TestHostKeysVerify(t *testing.T) {
+	known := newTestSigner(t).PublicKey()
+	other := newTestSigner(t).PublicKey()
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+
+	tests := []struct {
+		name      string
+		acceptNew bool
+		host      string
+		key       ssh.PublicKey
+		wantErr   string
+		wantAdded bool
+	}{
+		{name: "known key", host: "bastion:22", key: known},
+		{name: "mismatch", host: "bastion:22", key: other, wantErr: "HOST KEY MISMATCH"},
+		{name: "mismatch with accept new", acceptNew: true, host: "bastion:22", key: other, wantErr: "HOST KEY MISMATCH"},
+		{name: "unknown host", host: "web-1:22", key: other, wantErr: "--accept-new"},
+		{name: "unknown host with accept new", acceptNew: true, host: "web-1:22", key: other, wantAdded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hk := newTestHostKeys(t, tt.acceptNew, knownhosts.Line([]string{"bastion"}, known))
+
+			err := hk.Callback()(tt.host, remote, tt.key)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("verify(%s) error = %v", tt.host, err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("verify(%s) error = %v, want %q", tt.host, err, tt.wantErr)
+			}
+
+			data, _ := os.ReadFile(hk.ManagedFile)
+			if added := strings.Contains(string(data), knownhosts.Normalize(tt.host)); added != tt.wantAdded {
+				t.Errorf("managed file %q, want host added %v", data, tt.wantAdded)
+			}
+
+			if tt.wantAdded {
+				// the key is known from now on, also without AcceptNew
+				hk.AcceptNew = false
+				err = hk.Callback()(tt.host, remote, tt.key)
+				if err != nil {
+					t.Errorf("verify(%s) after adding error = %v", tt.host, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHostKeysAlgorithms(t *testing.T) {
+	ed25519Key := newTestSigner(t).PublicKey()
+
+	ecdsaPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ssh.NewPublicKey(&ecdsaPrivate.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := ssh.NewPublicKey(&rsaPrivate.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hk := newTestHostKeys(t, false,
+		knownhosts.Line([]string{"bastion"}, ed25519Key),
+		knownhosts.Line([]string{"edge"}, ecdsaKey),
+		knownhosts.Line([]string{"[legacy]:2222"}, rsaKey),
+	)
+
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{"bastion:22", []string{ssh.KeyAlgoED25519}},
+		{"edge:22", []string{ssh.KeyAlgoECDSA256}},
+		{"legacy:2222", []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{"legacy:22", nil},
+		{"unknown:22", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got := hk.Algorithms(tt.address)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Algorithms(%s) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package ssh_jump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Pool connects to many targets through the same jump hosts. The connection
+// to the jump hosts is made and authenticated once and every target is dialled
+// through it; when it drops, the next Dial reconnects. Targets without hops of
+// their own share the connections of their ProxyJump route.
+//
+// Pool is safe for concurrent use. Close closes every connection the pool has
+// made, including targets that were not closed by their users, and the agent
+// connection of its Auth.
+type Pool struct {
+	Hops     []Hop
+	HostKeys *HostKeys
+	Auth     *Auth
+
+	mu      sync.Mutex
+	closed  bool
+	routes  map[string][]*ssh.Client
+	targets map[*ssh.Client]bool
+}
+
+// NewPool creates a Pool reaching its targets through hops. Nil hostKeys and
+// auth are defaulted as for SSHJump.
+func NewPool(hops []Hop, hostKeys *HostKeys, auth *Auth) (*Pool, error) {
+	auth, hostKeys, err := defaults(auth, hostKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pool{
+		Hops:     hops,
+		HostKeys: hostKeys,
+		Auth:     auth,
+		routes:   map[string][]*ssh.Client{},
+		targets:  map[*ssh.Client]bool{},
+	}, nil
+}
+
+// ErrPoolClosed is returned by Dial after the pool has been closed.
+var ErrPoolClosed = errors.New("SSH connection pool is closed")
+
+// Dial connects to target through the pool's jump hosts. The returned client
+// should be closed once done with; the jump connections stay open for other
+// targets until the pool is closed.
+func (p *Pool) Dial(target Hop) (*ssh.Client, error) {
+	route, ep, err := resolveRoute(p.Hops, target)
+	if err != nil {
+		return nil, err
+	}
+
+	via, err := p.jump(route, false)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dial(via, ep, p.Auth, p.HostKeys)
+	if err != nil && via != nil && !alive(via) {
+		// the jump connection dropped, reconnect once and try again
+		via, err = p.jump(route, true)
+		if err != nil {
+			return nil, err
+		}
+		client, err = dial(via, ep, p.Auth, p.HostKeys)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open target host %s: %w", ep.Alias, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		client.Close()
+		return nil, ErrPoolClosed
+	}
+
+	p.targets[client] = true
+	go func() {
+		client.Wait()
+
+		p.mu.Lock()
+		delete(p.targets, client)
+		p.mu.Unlock()
+	}()
+
+	return client, nil
+}
+
+// Connect connects to the jump hosts ahead of the first Dial, to report
+// connection and authentication problems early.
+func (p *Pool) Connect() error {
+	route, err := resolveHops(p.Hops)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.jump(route, false)
+
+	return err
+}
+
+// DialContext opens a TCP connection to address as seen from the last jump
+// host, reconnecting once if the jump connection dropped. It fails when the
+// pool has no hops.
+func (p *Pool) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if len(p.Hops) == 0 {
+		return nil, errors.New("no jump host to connect through")
+	}
+
+	route, err := resolveHops(p.Hops)
+	if err != nil {
+		return nil, err
+	}
+
+	via, err := p.jump(route, false)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := via.DialContext(ctx, network, address)
+	if err != nil && !alive(via) {
+		via, err = p.jump(route, true)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = via.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s through %s: %w", address, route[len(route)-1].Alias, err)
+	}
+
+	return conn, nil
+}
+
+// jump returns the connection at the end of route, connecting when there is
+// none yet or reconnect is set. It returns nil for an empty route. Connecting
+// holds the lock so that concurrent dials wait for one handshake rather than
+// each making their own.
+func (p *Pool) jump(route []Endpoint, reconnect bool) (*ssh.Client, error) {
+	if len(route) == 0 {
+		return nil, nil
+	}
+
+	key := routeKey(route)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	clients := p.routes[key]
+	if clients != nil && reconnect && !alive(clients[len(clients)-1]) {
+		closeClients(clients)
+		clients = nil
+	}
+
+	if clients == nil {
+		var err error
+		clients, err = connectRoute(route, p.Auth, p.HostKeys)
+		if err != nil {
+			delete(p.routes, key)
+			return nil, err
+		}
+		p.routes[key] = clients
+	}
+
+	return clients[len(clients)-1], nil
+}
+
+// Close closes the targets that are still open and then the jump connections.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	for client := range p.targets {
+		client.Close()
+	}
+	p.targets = map[*ssh.Client]bool{}
+
+	for key, clients := range p.routes {
+		closeClients(clients)
+		delete(p.routes, key)
+	}
+
+	p.Auth.Close()
+}
+
+// keepaliveTimeout is how long alive waits for a connection to answer.
+var keepaliveTimeout = 10 * time.Second
+
+// alive checks that a connection still answers, using the keepalive request
+// OpenSSH clients send. A half-open connection never answers, so one that does
+// not answer within keepaliveTimeout is closed and reported dead.
+func alive(client *ssh.Client) bool {
+	answered := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		answered <- err
+	}()
+
+	select {
+	case err := <-answered:
+		return err == nil
+	case <-time.After(keepaliveTimeout):
+		client.Close()
+		return false
+	}
+}
+
+// routeKey identifies a route by the users and addresses along it.
+func routeKey(route []Endpoint) string {
+	parts := make([]string, len(route))
+	for i, ep := range route {
+		parts[i] = ep.User + "@" + ep.Address()
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,174 @@
+package ssh_jump
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestAlive(t *testing.T) {
+	defer func(timeout time.Duration) { keepaliveTimeout = timeout }(keepaliveTimeout)
+	keepaliveTimeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name string
+		fail func(*testServer)
+		want bool
+	}{
+		{name: "answering", want: true},
+		{name: "half-open", fail: (*testServer).hang},
+		{name: "dropped", fail: (*testServer).drop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newTestSigner(t)
+			server := newTestServer(t, key.PublicKey())
+
+			client, err := ssh.Dial("tcp", server.listener.Addr().String(), &ssh.ClientConfig{
+				User:            "test",
+				Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			if tt.fail != nil {
+				tt.fail(server)
+			}
+
+			start := time.Now()
+			if got := alive(client); got != tt.want {
+				t.Errorf("alive() = %v, want %v", got, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("alive() took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	defer func(timeout time.Duration) { keepaliveTimeout = timeout }(keepaliveTimeout)
+	keepaliveTimeout = 200 * time.Millisecond
+
+	useTestConfig(t, "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	publicKey, file := writeTestKey(t, t.TempDir(), "id_ed25519", "")
+
+	// echo is the service reached through the bastion
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	dialAddress := func(ctx context.Context, pool *Pool) error {
+		conn, err := pool.DialContext(ctx, "tcp", echo.Addr().String())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = conn.Write([]byte("ping"))
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(conn, make([]byte, 4))
+		return err
+	}
+
+	tests := []struct {
+		name string
+		fail func(*testServer)
+		// dial a target SSH server rather than a TCP address
+		target bool
+	}{
+		{name: "address after a drop", fail: (*testServer).drop},
+		{name: "address after a hang", fail: (*testServer).hang},
+		{name: "target after a drop", fail: (*testServer).drop, target: true},
+		{name: "target after a hang", fail: (*testServer).hang, target: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bastion := newTestServer(t, publicKey)
+			target := newTestServer(t, publicKey)
+			bastionHost, bastionPort := bastion.addr()
+			targetHost, targetPort := target.addr()
+
+			hostKeys := newTestHostKeys(t, false,
+				knownhosts.Line([]string{knownhosts.Normalize(bastionHost + ":" + bastionPort)}, bastion.hostKey.PublicKey()),
+				knownhosts.Line([]string{knownhosts.Normalize(targetHost + ":" + targetPort)}, target.hostKey.PublicKey()),
+			)
+			auth, err := NewAuth([]string{AuthKey}, []string{file})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pool, err := NewPool([]Hop{{Host: bastionHost, Port: bastionPort, User: "ops"}}, hostKeys, auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			dial := func() error {
+				if !tt.target {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					return dialAddress(ctx, pool)
+				}
+
+				client, err := pool.Dial(Hop{Host: targetHost, Port: targetPort, User: "ops"})
+				if err != nil {
+					return err
+				}
+				return client.Close()
+			}
+
+			err = dial()
+			if err != nil {
+				t.Fatalf("first dial error = %v", err)
+			}
+
+			tt.fail(bastion)
+
+			err = dial()
+			if err != nil {
+				t.Fatalf("dial after the break error = %v", err)
+			}
+			if got := bastion.connections(); got != 2 {
+				t.Errorf("bastion accepted %d connections, want 2", got)
+			}
+
+			err = dial()
+			if err != nil {
+				t.Fatalf("dial after reconnecting error = %v", err)
+			}
+			if got := bastion.connections(); got != 2 {
+				t.Errorf("bastion accepted %d connections after reconnecting, want 2", got)
+			}
+		})
+	}
+}
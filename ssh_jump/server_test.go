@@ -0,0 +1,229 @@
+package ssh_jump
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server on localhost for the tests. It accepts the
+// authorized keys, and the password when one is set. It answers keepalives
+// and forwards direct-tcpip channels like a bastion, except on connections
+// that hang.
+type testServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mu         sync.Mutex
+	authorized map[string]bool
+	password   string
+	hung       map[net.Conn]bool
+	accepted   int
+	conns      []net.Conn
+}
+
+// newTestServer starts a testServer accepting the given public keys. It is
+// stopped when the test ends.
+func newTestServer(t *testing.T, authorized ...ssh.PublicKey) *testServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{
+		listener:   listener,
+		hostKey:    newTestSigner(t),
+		authorized: map[string]bool{},
+		hung:       map[net.Conn]bool{},
+	}
+	for _, key := range authorized {
+		s.authorized[string(key.Marshal())] = true
+	}
+
+	go s.serve()
+	t.Cleanup(s.close)
+
+	return s
+}
+
+// newTestSigner returns a new ed25519 key.
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+// addr returns the host and port the server listens on.
+func (s *testServer) addr() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+// hang makes the open connections stop answering keepalives and reject new
+// channels, as half-open connections would. Later connections work.
+func (s *testServer) hang() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		s.hung[conn] = true
+	}
+}
+
+func (s *testServer) isHung(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hung[conn]
+}
+
+// connections returns the number of connections accepted so far.
+func (s *testServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accepted
+}
+
+// drop closes every open connection, as a restarted bastion would.
+func (s *testServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testServer) close() {
+	s.listener.Close()
+	s.drop()
+}
+
+func (s *testServer) serve() {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if !s.authorized[string(key.Marshal())] {
+				return nil, errTestDenied
+			}
+			return nil, nil
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.password == "" || string(password) != s.password {
+				return nil, errTestDenied
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.accepted++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go s.handle(conn, config)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	go func() {
+		for req := range reqs {
+			if !s.isHung(conn) {
+				req.Reply(true, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if s.isHung(conn) {
+			newChannel.Reject(ssh.ConnectionFailed, "hung")
+			continue
+		}
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip")
+			continue
+		}
+
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		err := ssh.Unmarshal(newChannel.ExtraData(), &target)
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(channel, remote)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(remote, channel)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+var errTestDenied = errors.New("denied")
@@ -3,83 +3,246 @@ package ssh_jump
 
 import (
 	"fmt"
-	"log"
-	"net"
-	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 )
 
-// SSHJump represents an SSH client accessed through a bastion or jump host.
+// DefaultTargetUser is the user logged in as on target hosts when neither the
+// SSHJump nor the SSH config names one.
+const DefaultTargetUser = "ubuntu"
+
+// SSHJump represents an SSH client accessed through a chain of bastions or jump
+// hosts. Hops are dialled in order, each through the previous one, and the
+// Target through the last; Hops may be empty to connect directly or through
+// the target's own ProxyJump.
+//
+// HostKeys verifies the keys of every host; when nil, the default known_hosts
+// files are used and unknown hosts are rejected. Auth provides the
+// authentication methods for hops without their own; when nil, the agent and
+// default keys are tried.
+//
+// An SSHJump connects to a single target. Use a Pool to reach many targets
+// through the same jump hosts.
 type SSHJump struct {
-	JumpHost   string
-	JumpUser   string
-	TargetHost string
-	TargetUser string
+	Hops     []Hop
+	Target   Hop
+	HostKeys *HostKeys
+	Auth     *Auth
 
-	jumpConnection   *ssh.Client
+	jumpConnections  []*ssh.Client
 	targetConnection *ssh.Client
 
 	Client *ssh.Client
 }
 
-// Helper method for constructing a new SSHJump struct.
+// Helper method for constructing a new SSHJump struct with a single jump host.
 func New(jump_host string, jump_user string, target_host string, target_user string) *SSHJump {
+	var hops []Hop
+	if jump_host != "" {
+		hops = append(hops, Hop{Host: jump_host, User: jump_user})
+	}
+
+	return NewChain(hops, Hop{Host: target_host, User: target_user})
+}
+
+// NewChain constructs an SSHJump reaching target through hops.
+func NewChain(hops []Hop, target Hop) *SSHJump {
 	return &SSHJump{
-		JumpHost:   jump_host,
-		JumpUser:   jump_user,
-		TargetHost: target_host,
-		TargetUser: target_user,
+		Hops:   hops,
+		Target: target,
 	}
 }
 
-// Connect to the target server through the bastion server. Creates the Client field of the struct.
+// Connect to the target server through the bastion servers. Creates the Client field of the struct.
+//
+// Every host is resolved through the SSH config, so Host aliases, User, Port
+// and IdentityFile apply; explicit hop users and ports win over the config.
+// The ProxyJump of the first hop is honoured, and without hops the target's
+// own ProxyJump is used.
 func (sj *SSHJump) Connect() error {
-	socket := os.Getenv("SSH_AUTH_SOCK")
-	conn, err := net.Dial("unix", socket)
+	var err error
+
+	sj.Auth, sj.HostKeys, err = defaults(sj.Auth, sj.HostKeys)
 	if err != nil {
-		return fmt.Errorf("Failed to open SSH_AUTH_SOCK: %w", err)
+		return err
 	}
 
-	agentClient := agent.NewClient(conn)
-	sshConfig := &ssh.ClientConfig{
-		User: sj.JumpUser,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeysCallback(agentClient.Signers),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
+	route, target, err := resolveRoute(sj.Hops, sj.Target)
+	if err != nil {
+		return err
 	}
 
-	jumpConn, err := ssh.Dial("tcp", sj.JumpHost+":22", sshConfig)
+	sj.jumpConnections, err = connectRoute(route, sj.Auth, sj.HostKeys)
 	if err != nil {
-		log.Fatalf("Failed to SSH to jump host: %v", err)
+		return err
 	}
 
-	sshConfig.User = sj.TargetUser
-	conn, err = jumpConn.Dial("tcp", sj.TargetHost+":22")
-	if err != nil {
-		return fmt.Errorf("Failed to open target host: %w", err)
+	var via *ssh.Client
+	if len(sj.jumpConnections) > 0 {
+		via = sj.jumpConnections[len(sj.jumpConnections)-1]
 	}
 
-	ncc, chans, reqs, err := ssh.NewClientConn(conn, sj.TargetHost+":22", sshConfig)
+	sj.targetConnection, err = dial(via, target, sj.Auth, sj.HostKeys)
 	if err != nil {
-		return fmt.Errorf("Failed to launch client connection: %w", err)
+		sj.Close()
+		return fmt.Errorf("Failed to open target host %s: %w", target.Alias, err)
 	}
+	sj.Client = sj.targetConnection
 
-	sj.Client = ssh.NewClient(ncc, chans, reqs)
 	return nil
 }
 
-// Close all SSH connections if open.
-func (sj *SSHJump) Close() {
-	if sj.jumpConnection != nil {
-		sj.jumpConnection.Close()
+// defaults fills in the Auth and HostKeys used when none are given.
+func defaults(auth *Auth, hostKeys *HostKeys) (*Auth, *HostKeys, error) {
+	var err error
+
+	if auth == nil {
+		auth, err = NewAuth(DefaultAuthOrder, nil)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
+
+	if hostKeys == nil {
+		hostKeys, err = NewHostKeys(false)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return auth, hostKeys, nil
+}
+
+// resolveRoute resolves the hops and target into the endpoints to dial through
+// and the target endpoint.
+func resolveRoute(hops []Hop, targetHop Hop) ([]Endpoint, Endpoint, error) {
+	target, err := targetHop.resolve(DefaultTargetUser)
+	if err != nil {
+		return nil, target, err
+	}
+
+	if len(hops) == 0 {
+		route := target.Route()
+		return route[:len(route)-1], target, nil
+	}
+
+	route, err := resolveHops(hops)
+
+	return route, target, err
+}
+
+// resolveHops resolves hops into the endpoints to dial, in order.
+func resolveHops(hops []Hop) ([]Endpoint, error) {
+	var route []Endpoint
+	for i, hop := range hops {
+		ep, err := hop.resolve(localUser())
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			route = append(route, ep.Route()...)
+		} else {
+			route = append(route, ep)
+		}
+	}
+
+	return route, nil
+}
+
+// connectRoute dials the endpoints of a route in order, each through the
+// previous one, and returns the connections. On failure the connections made
+// so far are closed.
+func connectRoute(route []Endpoint, auth *Auth, hostKeys *HostKeys) ([]*ssh.Client, error) {
+	var clients []*ssh.Client
+	var via *ssh.Client
+
+	for _, hop := range route {
+		client, err := dial(via, hop, auth, hostKeys)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("Failed to SSH to jump host %s: %w", hop.Alias, err)
+		}
+
+		clients = append(clients, client)
+		via = client
+	}
+
+	return clients, nil
+}
+
+// closeClients closes connections in reverse order, so that tunnelled
+// connections go before the connections carrying them.
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
+
+// dial connects to an endpoint, through the via client when it is not nil.
+// The endpoint's own Auth, when set, is used instead of auth. Authentication
+// failures list the methods that were tried.
+func dial(via *ssh.Client, ep Endpoint, auth *Auth, hostKeys *HostKeys) (*ssh.Client, error) {
+	if ep.Auth != nil {
+		auth = ep.Auth
+	}
+	methods, attempts := auth.Methods(ep)
+
+	config := &ssh.ClientConfig{
+		User:              ep.User,
+		Auth:              methods,
+		HostKeyCallback:   hostKeys.Callback(),
+		HostKeyAlgorithms: hostKeys.Algorithms(ep.Address()),
+		Timeout:           5 * time.Second,
+	}
+
+	var client *ssh.Client
+	var err error
+
+	if via == nil {
+		client, err = ssh.Dial("tcp", ep.Address(), config)
+	} else {
+		client, err = dialVia(via, ep.Address(), config)
+	}
+
+	if err != nil && strings.Contains(err.Error(), "unable to authenticate") {
+		return nil, fmt.Errorf("%w (as %s; tried %s)", err, ep.User, attempts)
+	}
+
+	return client, err
+}
+
+// dialVia opens an SSH connection to address tunnelled through via.
+func dialVia(via *ssh.Client, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := via.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	ncc, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Failed to launch client connection: %w", err)
+	}
+
+	return ssh.NewClient(ncc, chans, reqs), nil
+}
+
+// Close all SSH connections if open, the target first, and the agent
+// connection of the Auth.
+func (sj *SSHJump) Close() {
 	if sj.targetConnection != nil {
 		sj.targetConnection.Close()
+		sj.targetConnection = nil
+	}
+
+	closeClients(sj.jumpConnections)
+	sj.jumpConnections = nil
+
+	if sj.Auth != nil {
+		sj.Auth.Close()
 	}
 }
 
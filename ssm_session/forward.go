@@ -0,0 +1,171 @@
+package ssm_session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Port forwarding documents.
+const (
+	portForwardingDocument       = "AWS-StartPortForwardingSession"
+	remotePortForwardingDocument = "AWS-StartPortForwardingSessionToRemoteHost"
+)
+
+// forwardParameters returns the document and parameters of a port forwarding
+// session to host:port as seen from the instance. An empty host forwards to a
+// port of the instance itself.
+func forwardParameters(host string, port string, localPort string) (string, map[string][]string) {
+	parameters := map[string][]string{
+		"portNumber":      {port},
+		"localPortNumber": {localPort},
+	}
+	if host == "" {
+		return portForwardingDocument, parameters
+	}
+
+	parameters["host"] = []string{host}
+
+	return remotePortForwardingDocument, parameters
+}
+
+// Forward accepts connections on listener and forwards each to host:port
+// through a port forwarding session of its own on target, until ctx is done
+// or accepting fails. An empty host forwards to a port of the target itself.
+// It then closes the listener and the open sessions and returns once they are
+// all closed. Failures of a single connection are logged and do not stop the
+// forward.
+func (mgr *SessionManager) Forward(ctx context.Context, listener net.Listener, target string, host string, port string) error {
+	localPort := "0"
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		localPort = strconv.Itoa(addr.Port)
+	}
+	document, parameters := forwardParameters(host, port, localPort)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	closing := false
+	open := map[io.Closer]bool{}
+
+	// track registers a connection or session to close on shutdown, and
+	// reports false once shutdown began
+	track := func(c io.Closer) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if closing {
+			return false
+		}
+		open[c] = true
+
+		return true
+	}
+	untrack := func(c io.Closer) {
+		mu.Lock()
+		delete(open, c)
+		mu.Unlock()
+		c.Close()
+	}
+	closeAll := func() {
+		listener.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		closing = true
+		for c := range open {
+			c.Close()
+		}
+	}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeAll()
+		case <-stopped:
+		}
+	}()
+
+	var err error
+	for {
+		var local net.Conn
+		local, err = listener.Accept()
+		if err != nil {
+			break
+		}
+
+		if !track(local) {
+			local.Close()
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer untrack(local)
+
+			session, err := mgr.Start(ctx, target, document, parameters)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("forward %s: %v", listener.Addr(), err)
+				}
+				return
+			}
+
+			if !track(session) {
+				session.Close()
+				return
+			}
+			defer untrack(session)
+
+			pipe(local, session)
+
+			// a session that ended by itself may say why
+			select {
+			case <-session.Done():
+				err = session.Wait()
+				if err != nil && ctx.Err() == nil {
+					log.Printf("forward %s: %v", listener.Addr(), err)
+				}
+			default:
+			}
+		}()
+	}
+
+	// a failed accept leaves the forwarded sessions to be closed
+	closeAll()
+	wg.Wait()
+
+	if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return fmt.Errorf("forward %s stopped: %w", listener.Addr(), err)
+}
+
+// pipe copies between a connection and a session until either side is done.
+func pipe(conn net.Conn, session *Session) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		io.Copy(conn, session)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(session, conn)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-session.Done():
+	}
+}
@@ -0,0 +1,191 @@
+package ssm_session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message types of the data channel.
+const (
+	inputStreamMessage      = "input_stream_data"
+	outputStreamMessage     = "output_stream_data"
+	acknowledgeMessage      = "acknowledge"
+	channelClosedMessage    = "channel_closed"
+	startPublicationMessage = "start_publication"
+	pausePublicationMessage = "pause_publication"
+)
+
+// Payload types of stream messages.
+const (
+	payloadOutput            uint32 = 1
+	payloadError             uint32 = 2
+	payloadSize              uint32 = 3
+	payloadParameter         uint32 = 4
+	payloadHandshakeRequest  uint32 = 5
+	payloadHandshakeResponse uint32 = 6
+	payloadHandshakeComplete uint32 = 7
+	payloadFlag              uint32 = 10
+	payloadStdErr            uint32 = 11
+	payloadExitCode          uint32 = 12
+)
+
+// messageFlagAck is the header flag of acknowledge messages.
+const messageFlagAck uint64 = 3
+
+// Values of a payloadFlag message.
+const (
+	flagDisconnectToPort   uint32 = 1
+	flagTerminateSession   uint32 = 2
+	flagConnectToPortError uint32 = 3
+)
+
+// Layout of the binary message header. headerLength is the value of the
+// header length field: the offset of the payload length field, which
+// excludes itself.
+const (
+	messageTypeLength   = 32
+	messageIDLength     = 16
+	payloadDigestLength = 32
+
+	messageTypeOffset    = 4
+	schemaVersionOffset  = messageTypeOffset + messageTypeLength
+	createdDateOffset    = schemaVersionOffset + 4
+	sequenceNumberOffset = createdDateOffset + 8
+	flagsOffset          = sequenceNumberOffset + 8
+	messageIDOffset      = flagsOffset + 8
+	payloadDigestOffset  = messageIDOffset + messageIDLength
+	payloadTypeOffset    = payloadDigestOffset + payloadDigestLength
+	payloadLengthOffset  = payloadTypeOffset + 4
+	payloadOffset        = payloadLengthOffset + 4
+
+	headerLength = payloadLengthOffset
+)
+
+// messageID is the UUID of a message.
+type messageID [messageIDLength]byte
+
+// newMessageID returns a random version 4 UUID.
+func newMessageID() messageID {
+	var id messageID
+
+	_, err := rand.Read(id[:])
+	if err != nil {
+		panic(fmt.Sprintf("unable to generate a message id: %v", err))
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return id
+}
+
+// String returns the UUID in its canonical form, as used in acknowledgements.
+func (id messageID) String() string {
+	var buf [36]byte
+
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+
+	return string(buf[:])
+}
+
+// clientMessage is a message on the data channel, in either direction.
+type clientMessage struct {
+	MessageType    string
+	SchemaVersion  uint32
+	CreatedDate    time.Time
+	SequenceNumber int64
+	Flags          uint64
+	MessageID      messageID
+	PayloadType    uint32
+	Payload        []byte
+}
+
+// newMessage returns a message of the given type with a new ID.
+func newMessage(messageType string, sequenceNumber int64, payloadType uint32, payload []byte) *clientMessage {
+	return &clientMessage{
+		MessageType:    messageType,
+		SchemaVersion:  1,
+		CreatedDate:    time.Now(),
+		SequenceNumber: sequenceNumber,
+		MessageID:      newMessageID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+}
+
+// MarshalBinary encodes the message. All integers are big endian, and the
+// message ID is stored as its second half followed by its first half.
+func (msg *clientMessage) MarshalBinary() ([]byte, error) {
+	if len(msg.MessageType) > messageTypeLength {
+		return nil, fmt.Errorf("message type %q is too long", msg.MessageType)
+	}
+
+	buf := make([]byte, payloadOffset+len(msg.Payload))
+
+	binary.BigEndian.PutUint32(buf, headerLength)
+	copy(buf[messageTypeOffset:schemaVersionOffset], bytes.Repeat([]byte{' '}, messageTypeLength))
+	copy(buf[messageTypeOffset:], msg.MessageType)
+	binary.BigEndian.PutUint32(buf[schemaVersionOffset:], msg.SchemaVersion)
+	binary.BigEndian.PutUint64(buf[createdDateOffset:], uint64(msg.CreatedDate.UnixMilli()))
+	binary.BigEndian.PutUint64(buf[sequenceNumberOffset:], uint64(msg.SequenceNumber))
+	binary.BigEndian.PutUint64(buf[flagsOffset:], msg.Flags)
+	copy(buf[messageIDOffset:], msg.MessageID[8:])
+	copy(buf[messageIDOffset+8:], msg.MessageID[:8])
+
+	digest := sha256.Sum256(msg.Payload)
+	copy(buf[payloadDigestOffset:], digest[:])
+
+	binary.BigEndian.PutUint32(buf[payloadTypeOffset:], msg.PayloadType)
+	binary.BigEndian.PutUint32(buf[payloadLengthOffset:], uint32(len(msg.Payload)))
+	copy(buf[payloadOffset:], msg.Payload)
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a message and checks its payload digest.
+func (msg *clientMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < payloadOffset {
+		return errors.New("message is shorter than its header")
+	}
+
+	length := binary.BigEndian.Uint32(data)
+	if length != headerLength {
+		return fmt.Errorf("unexpected header length %d", length)
+	}
+
+	payloadLength := binary.BigEndian.Uint32(data[payloadLengthOffset:])
+	if uint64(len(data)-payloadOffset) < uint64(payloadLength) {
+		return errors.New("message is shorter than its payload")
+	}
+
+	msg.MessageType = string(bytes.TrimRight(data[messageTypeOffset:schemaVersionOffset], " \x00"))
+	msg.SchemaVersion = binary.BigEndian.Uint32(data[schemaVersionOffset:])
+	msg.CreatedDate = time.UnixMilli(int64(binary.BigEndian.Uint64(data[createdDateOffset:])))
+	msg.SequenceNumber = int64(binary.BigEndian.Uint64(data[sequenceNumberOffset:]))
+	msg.Flags = binary.BigEndian.Uint64(data[flagsOffset:])
+	copy(msg.MessageID[8:], data[messageIDOffset:messageIDOffset+8])
+	copy(msg.MessageID[:8], data[messageIDOffset+8:payloadDigestOffset])
+	msg.PayloadType = binary.BigEndian.Uint32(data[payloadTypeOffset:])
+	msg.Payload = data[payloadOffset : payloadOffset+int(payloadLength)]
+
+	// the digest of an empty payload is not always set
+	digest := sha256.Sum256(msg.Payload)
+	if payloadLength > 0 && !bytes.Equal(digest[:], data[payloadDigestOffset:payloadTypeOffset]) {
+		return fmt.Errorf("payload digest mismatch in %s message %d", msg.MessageType, msg.SequenceNumber)
+	}
+
+	return nil
+}
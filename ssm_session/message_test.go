@@ -0,0 +1,172 @@
+package ssm_session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	id := messageID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	tests := []struct {
+		name string
+		msg  clientMessage
+	}{
+		{
+			name: "output",
+			msg: clientMessage{
+				MessageType:    outputStreamMessage,
+				SchemaVersion:  1,
+				CreatedDate:    time.UnixMilli(1700000000123),
+				SequenceNumber: 42,
+				MessageID:      id,
+				PayloadType:    payloadOutput,
+				Payload:        []byte("hello\r\n"),
+			},
+		},
+		{
+			name: "acknowledge",
+			msg: clientMessage{
+				MessageType:   acknowledgeMessage,
+				SchemaVersion: 1,
+				CreatedDate:   time.UnixMilli(1700000000000),
+				Flags:         messageFlagAck,
+				MessageID:     id,
+				Payload:       []byte(`{"AcknowledgedMessageSequenceNumber":41}`),
+			},
+		},
+		{
+			name: "empty payload",
+			msg: clientMessage{
+				MessageType:    channelClosedMessage,
+				SchemaVersion:  1,
+				CreatedDate:    time.UnixMilli(0),
+				SequenceNumber: -1,
+				MessageID:      id,
+				Payload:        []byte{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(data) != payloadOffset+len(tt.msg.Payload) {
+				t.Errorf("encoded %d bytes, want %d", len(data), payloadOffset+len(tt.msg.Payload))
+			}
+
+			var got clientMessage
+			err = got.UnmarshalBinary(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.msg) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMessageLayout(t *testing.T) {
+	msg := newMessage(inputStreamMessage, 7, payloadSize, []byte("{}"))
+	msg.MessageID = messageID{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := binary.BigEndian.Uint32(data); got != 116 {
+		t.Errorf("header length = %d, want 116", got)
+	}
+	if got := string(data[messageTypeOffset:schemaVersionOffset]); got != inputStreamMessage+strings.Repeat(" ", messageTypeLength-len(inputStreamMessage)) {
+		t.Errorf("message type = %q, want it padded with spaces", got)
+	}
+
+	// the second half of the ID comes first
+	want := []byte{8, 9, 10, 11, 12, 13, 14, 15, 0, 1, 2, 3, 4, 5, 6, 7}
+	if got := data[messageIDOffset:payloadDigestOffset]; !bytes.Equal(got, want) {
+		t.Errorf("encoded message ID = %v, want %v", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	valid := func() []byte {
+		data, err := newMessage(outputStreamMessage, 1, payloadOutput, []byte("hello")).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		wantErr string
+	}{
+		{
+			name:    "short header",
+			data:    func() []byte { return valid()[:payloadOffset-1] },
+			wantErr: "shorter than its header",
+		},
+		{
+			name: "header length",
+			data: func() []byte {
+				data := valid()
+				binary.BigEndian.PutUint32(data, headerLength+4)
+				return data
+			},
+			wantErr: "unexpected header length",
+		},
+		{
+			name:    "short payload",
+			data:    func() []byte { return valid()[:payloadOffset+2] },
+			wantErr: "shorter than its payload",
+		},
+		{
+			name: "digest",
+			data: func() []byte {
+				data := valid()
+				data[payloadOffset] = 'j'
+				return data
+			},
+			wantErr: "payload digest mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg clientMessage
+			err := msg.UnmarshalBinary(tt.data())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UnmarshalBinary() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a long message type cannot be encoded
+	_, err := newMessage(strings.Repeat("x", messageTypeLength+1), 1, payloadOutput, nil).MarshalBinary()
+	if err == nil {
+		t.Error("MarshalBinary() with a long message type succeeded, want an error")
+	}
+}
+
+func TestMessageID(t *testing.T) {
+	id := messageID{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	if got := id.String(); got != "12345678-9abc-def0-0123-456789abcdef" {
+		t.Errorf("String() = %q", got)
+	}
+
+	random := newMessageID()
+	if random[6]>>4 != 4 || random[8]>>6 != 2 {
+		t.Errorf("newMessageID() = %s, want a version 4 UUID", random)
+	}
+}
@@ -0,0 +1,702 @@
+// Package ssm_session is a client for AWS Systems Manager Session Manager. It
+// speaks the data channel protocol of the session-manager-plugin, so sessions
+// can be started without the AWS CLI.
+//
+// KMS encrypted sessions are not supported; Start returns
+// ErrEncryptionUnsupported for them.
+package ssm_session
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/gorilla/websocket"
+)
+
+// clientVersion is the session-manager-plugin version kci presents to the
+// agent. Agents multiplex port sessions for clients from 1.1.70 on, which kci
+// does not implement.
+const clientVersion = "1.1.61.0"
+
+// inputChunkSize is the largest payload sent in one input message.
+const inputChunkSize = 1024
+
+// resendTimeout is how long an input message may go unacknowledged before it
+// is sent again.
+var resendTimeout = 3 * time.Second
+
+// pingInterval keeps the websocket from being closed as idle.
+const pingInterval = 5 * time.Minute
+
+// Action status codes of a handshake response.
+const (
+	actionSuccess     = 1
+	actionFailed      = 2
+	actionUnsupported = 3
+)
+
+var (
+	// ErrEncryptionUnsupported is returned when the session requires KMS
+	// encryption.
+	ErrEncryptionUnsupported = errors.New("KMS encrypted sessions are not supported")
+
+	// ErrConnectToPort is returned when the agent cannot connect to the
+	// remote port of a port forwarding session.
+	ErrConnectToPort = errors.New("the instance was unable to connect to the remote port")
+
+	// ErrSessionClosed is returned by Write after the session ended.
+	ErrSessionClosed = errors.New("session closed")
+)
+
+// SessionManager starts sessions. Messages receives the informational
+// messages of the agent, such as the notice when a session ends; nil discards
+// them.
+type SessionManager struct {
+	Client   *ssm.Client
+	Messages io.Writer
+}
+
+// NewManagerWithClient creates a new SessionManager with a supplied aws client.
+func NewManagerWithClient(client *ssm.Client) *SessionManager {
+	return &SessionManager{
+		Client: client,
+	}
+}
+
+// NewManager creates a new SessionManager with a client for the given AWS
+// config.
+func NewManager(cfg aws.Config) (*SessionManager, error) {
+	client := ssm.NewFromConfig(cfg)
+
+	return NewManagerWithClient(client), nil
+}
+
+// Session is a Session Manager session. Reading returns the output of the
+// session and writing sends input, so for a shell session it is the terminal,
+// and for a port forwarding session the forwarded connection. Type is the
+// session type the agent announced, e.g. Standard_Stream or Port.
+type Session struct {
+	ID   string
+	Type string
+
+	client   *ssm.Client
+	conn     *websocket.Conn
+	messages io.Writer
+
+	output       *io.PipeReader
+	outputWriter *io.PipeWriter
+
+	// writeMu serialises writes to the websocket, and the allocation of
+	// sequence numbers so that input is sent in order
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	ready     bool
+	paused    bool
+	finished  bool
+	err       error
+	outSeq    int64
+	unacked   map[int64]*pendingMessage
+	inSeq     int64
+	buffered  map[int64]*clientMessage
+	done      chan struct{}
+	closeOnce sync.Once
+
+	terminateOnce sync.Once
+}
+
+// pendingMessage is an input message waiting for its acknowledgement.
+type pendingMessage struct {
+	data []byte
+	sent time.Time
+}
+
+// acknowledgement is the payload of an acknowledge message.
+type acknowledgement struct {
+	AcknowledgedMessageType           string `json:"AcknowledgedMessageType"`
+	AcknowledgedMessageId             string `json:"AcknowledgedMessageId"`
+	AcknowledgedMessageSequenceNumber int64  `json:"AcknowledgedMessageSequenceNumber"`
+	IsSequentialMessage               bool   `json:"IsSequentialMessage"`
+}
+
+// handshakeRequest is sent by the agent before any output.
+type handshakeRequest struct {
+	AgentVersion           string `json:"AgentVersion"`
+	RequestedClientActions []struct {
+		ActionType       string          `json:"ActionType"`
+		ActionParameters json.RawMessage `json:"ActionParameters"`
+	} `json:"RequestedClientActions"`
+}
+
+// processedAction is the answer to one requested client action.
+type processedAction struct {
+	ActionType   string `json:"ActionType"`
+	ActionStatus int    `json:"ActionStatus"`
+	Error        string `json:"Error,omitempty"`
+}
+
+// handshakeResponse answers a handshakeRequest.
+type handshakeResponse struct {
+	ClientVersion          string            `json:"ClientVersion"`
+	ProcessedClientActions []processedAction `json:"ProcessedClientActions"`
+	Errors                 []string          `json:"Errors"`
+}
+
+// Start starts a session on an instance. An empty document starts a shell,
+// otherwise the document is started with its parameters, e.g.
+// AWS-StartPortForwardingSessionToRemoteHost. Start returns once the agent is
+// ready for input; ctx only bounds the start.
+func (mgr *SessionManager) Start(ctx context.Context, target string, document string, parameters map[string][]string) (*Session, error) {
+	input := &ssm.StartSessionInput{
+		Target: aws.String(target),
+	}
+	if document != "" {
+		input.DocumentName = aws.String(document)
+		input.Parameters = parameters
+	}
+
+	resp, err := mgr.Client.StartSession(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start a session on %s: %w", target, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, aws.ToString(resp.StreamUrl), nil)
+	if err != nil {
+		terminate(mgr.Client, aws.ToString(resp.SessionId))
+		return nil, fmt.Errorf("unable to open the data channel of session %s: %w", aws.ToString(resp.SessionId), err)
+	}
+
+	messages := mgr.Messages
+	if messages == nil {
+		messages = io.Discard
+	}
+
+	output, outputWriter := io.Pipe()
+	s := &Session{
+		ID:           aws.ToString(resp.SessionId),
+		client:       mgr.Client,
+		conn:         conn,
+		messages:     messages,
+		output:       output,
+		outputWriter: outputWriter,
+		unacked:      map[int64]*pendingMessage{},
+		buffered:     map[int64]*clientMessage{},
+		done:         make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	err = s.openDataChannel(aws.ToString(resp.TokenValue))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	go s.receive()
+	go s.maintain()
+
+	err = s.waitReady(ctx)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// openDataChannel authenticates the websocket with the session token.
+func (s *Session) openDataChannel(token string) error {
+	open := map[string]string{
+		"MessageSchemaVersion": "1.0",
+		"RequestId":            newMessageID().String(),
+		"TokenValue":           token,
+		"ClientId":             newMessageID().String(),
+		"ClientVersion":        clientVersion,
+	}
+
+	data, err := json.Marshal(open)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	err = s.conn.WriteMessage(websocket.TextMessage, data)
+	if err != nil {
+		return fmt.Errorf("unable to open the data channel of session %s: %w", s.ID, err)
+	}
+
+	return nil
+}
+
+// waitReady waits for the handshake to complete, or for the first output of
+// agents that do not shake hands.
+func (s *Session) waitReady(ctx context.Context) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			ready := s.ready
+			s.mu.Unlock()
+
+			if !ready {
+				s.finish(ctx.Err())
+			}
+		case <-stop:
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for !s.ready && !s.finished {
+		s.cond.Wait()
+	}
+	if !s.ready {
+		if s.err == nil {
+			return ErrSessionClosed
+		}
+		return s.err
+	}
+
+	return nil
+}
+
+// Read reads the output of the session. It returns io.EOF once the session
+// has ended.
+func (s *Session) Read(p []byte) (int, error) {
+	return s.output.Read(p)
+}
+
+// Write sends input to the session.
+func (s *Session) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		n := len(p)
+		if n > inputChunkSize {
+			n = inputChunkSize
+		}
+
+		err := s.send(payloadOutput, p[:n])
+		if err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// SetSize sets the terminal size of a shell session.
+func (s *Session) SetSize(cols int, rows int) error {
+	data, err := json.Marshal(map[string]int{"cols": cols, "rows": rows})
+	if err != nil {
+		return err
+	}
+
+	return s.send(payloadSize, data)
+}
+
+// Done returns a channel that is closed when the session has ended.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait waits for the session to end. It returns nil when the agent closed
+// the session.
+func (s *Session) Wait() error {
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Close ends the session and terminates it on the AWS side. Only the first
+// call has any effect.
+func (s *Session) Close() error {
+	var err error
+
+	s.terminateOnce.Do(func() {
+		s.writeMu.Lock()
+		s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+
+		s.finish(nil)
+
+		err = terminate(s.client, s.ID)
+	})
+
+	return err
+}
+
+// terminate terminates a session on the AWS side.
+func terminate(client *ssm.Client, sessionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := client.TerminateSession(ctx, &ssm.TerminateSessionInput{
+		SessionId: aws.String(sessionID),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to terminate session %s: %w", sessionID, err)
+	}
+
+	return nil
+}
+
+// finish ends the session with err, unless it has already ended.
+func (s *Session) finish(err error) {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	s.err = err
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+
+		if err == nil {
+			err = io.EOF
+		}
+		s.outputWriter.CloseWithError(err)
+	})
+}
+
+// send sends an input message once the session is ready, and keeps it until
+// it is acknowledged.
+func (s *Session) send(payloadType uint32, payload []byte) error {
+	s.mu.Lock()
+	for (!s.ready || s.paused) && !s.finished {
+		s.cond.Wait()
+	}
+	if s.finished {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	s.mu.Unlock()
+
+	return s.sendInput(payloadType, payload)
+}
+
+// sendInput sends an input message without waiting for the session to be
+// ready, as the handshake response must be.
+func (s *Session) sendInput(payloadType uint32, payload []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	msg := newMessage(inputStreamMessage, s.outSeq, payloadType, payload)
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.unacked[s.outSeq] = &pendingMessage{data: data, sent: time.Now()}
+	s.outSeq++
+	s.mu.Unlock()
+
+	err = s.conn.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		s.finish(fmt.Errorf("unable to send to session %s: %w", s.ID, err))
+		return ErrSessionClosed
+	}
+
+	return nil
+}
+
+// acknowledge acknowledges an output message.
+func (s *Session) acknowledge(msg *clientMessage) error {
+	payload, err := json.Marshal(acknowledgement{
+		AcknowledgedMessageType:           msg.MessageType,
+		AcknowledgedMessageId:             msg.MessageID.String(),
+		AcknowledgedMessageSequenceNumber: msg.SequenceNumber,
+		IsSequentialMessage:               true,
+	})
+	if err != nil {
+		return err
+	}
+
+	ack := newMessage(acknowledgeMessage, 0, 0, payload)
+	ack.Flags = messageFlagAck
+
+	data, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return s.conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// receive reads the data channel until the session ends.
+func (s *Session) receive() {
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				err = nil
+			} else {
+				err = fmt.Errorf("session %s: %w", s.ID, err)
+			}
+			s.finish(err)
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		msg := &clientMessage{}
+		err = msg.UnmarshalBinary(data)
+		if err != nil {
+			s.finish(fmt.Errorf("session %s: %w", s.ID, err))
+			return
+		}
+
+		err = s.handle(msg)
+		if err != nil {
+			s.finish(err)
+			return
+		}
+	}
+}
+
+// handle processes one message from the agent.
+func (s *Session) handle(msg *clientMessage) error {
+	switch msg.MessageType {
+	case outputStreamMessage:
+		err := s.acknowledge(msg)
+		if err != nil {
+			return fmt.Errorf("unable to acknowledge output of session %s: %w", s.ID, err)
+		}
+
+		// output is processed in sequence, whatever order it arrives in
+		s.mu.Lock()
+		if msg.SequenceNumber < s.inSeq {
+			s.mu.Unlock()
+			return nil
+		}
+		s.buffered[msg.SequenceNumber] = msg
+		s.mu.Unlock()
+
+		for {
+			s.mu.Lock()
+			next, ok := s.buffered[s.inSeq]
+			if ok {
+				delete(s.buffered, s.inSeq)
+				s.inSeq++
+			}
+			s.mu.Unlock()
+
+			if !ok {
+				return nil
+			}
+
+			err := s.handleOutput(next)
+			if err != nil {
+				return err
+			}
+		}
+
+	case acknowledgeMessage:
+		var ack acknowledgement
+		err := json.Unmarshal(msg.Payload, &ack)
+		if err != nil {
+			return fmt.Errorf("invalid acknowledgement in session %s: %w", s.ID, err)
+		}
+
+		s.mu.Lock()
+		delete(s.unacked, ack.AcknowledgedMessageSequenceNumber)
+		s.mu.Unlock()
+
+	case channelClosedMessage:
+		var closed struct {
+			Output string `json:"Output"`
+		}
+		json.Unmarshal(msg.Payload, &closed)
+		if closed.Output != "" {
+			fmt.Fprintln(s.messages, closed.Output)
+		}
+
+		s.finish(nil)
+
+	case startPublicationMessage, pausePublicationMessage:
+		s.mu.Lock()
+		s.paused = msg.MessageType == pausePublicationMessage
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// handleOutput processes an output message in sequence.
+func (s *Session) handleOutput(msg *clientMessage) error {
+	switch msg.PayloadType {
+	case payloadOutput, payloadStdErr:
+		s.setReady()
+
+		_, err := s.outputWriter.Write(msg.Payload)
+		if err != nil {
+			// nobody reads the output any more
+			return nil
+		}
+
+	case payloadHandshakeRequest:
+		return s.handshake(msg.Payload)
+
+	case payloadHandshakeComplete:
+		var complete struct {
+			CustomerMessage string `json:"CustomerMessage"`
+		}
+		json.Unmarshal(msg.Payload, &complete)
+		if complete.CustomerMessage != "" {
+			fmt.Fprintln(s.messages, complete.CustomerMessage)
+		}
+
+		s.setReady()
+
+	case payloadFlag:
+		if len(msg.Payload) < 4 {
+			return nil
+		}
+
+		switch binary.BigEndian.Uint32(msg.Payload) {
+		case flagConnectToPortError:
+			return ErrConnectToPort
+		case flagTerminateSession:
+			s.finish(nil)
+		}
+	}
+
+	return nil
+}
+
+// handshake answers the handshake request of the agent. Only the session
+// type action is supported.
+func (s *Session) handshake(payload []byte) error {
+	var request handshakeRequest
+	err := json.Unmarshal(payload, &request)
+	if err != nil {
+		return fmt.Errorf("invalid handshake in session %s: %w", s.ID, err)
+	}
+
+	response := handshakeResponse{
+		ClientVersion: clientVersion,
+		Errors:        []string{},
+	}
+	var failure error
+
+	for _, action := range request.RequestedClientActions {
+		processed := processedAction{ActionType: action.ActionType}
+
+		switch action.ActionType {
+		case "SessionType":
+			var parameters struct {
+				SessionType string `json:"SessionType"`
+			}
+			json.Unmarshal(action.ActionParameters, &parameters)
+
+			s.mu.Lock()
+			s.Type = parameters.SessionType
+			s.mu.Unlock()
+
+			processed.ActionStatus = actionSuccess
+
+		case "KMSEncryption":
+			processed.ActionStatus = actionFailed
+			processed.Error = ErrEncryptionUnsupported.Error()
+			response.Errors = append(response.Errors, processed.Error)
+			failure = ErrEncryptionUnsupported
+
+		default:
+			processed.ActionStatus = actionUnsupported
+			processed.Error = "unsupported action " + action.ActionType
+		}
+
+		response.ProcessedClientActions = append(response.ProcessedClientActions, processed)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	err = s.sendInput(payloadHandshakeResponse, data)
+	if err != nil {
+		return err
+	}
+
+	return failure
+}
+
+// setReady lets input through.
+func (s *Session) setReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ready {
+		s.ready = true
+		s.cond.Broadcast()
+	}
+}
+
+// maintain resends unacknowledged input and keeps the websocket alive until
+// the session ends.
+func (s *Session) maintain() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastPing := time.Now()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			var resend [][]byte
+
+			s.mu.Lock()
+			for _, pending := range s.unacked {
+				if now.Sub(pending.sent) >= resendTimeout {
+					pending.sent = now
+					resend = append(resend, pending.data)
+				}
+			}
+			s.mu.Unlock()
+
+			s.writeMu.Lock()
+			for _, data := range resend {
+				s.conn.WriteMessage(websocket.BinaryMessage, data)
+			}
+			if now.Sub(lastPing) >= pingInterval {
+				s.conn.WriteControl(websocket.PingMessage, nil, now.Add(10*time.Second))
+				lastPing = now
+			}
+			s.writeMu.Unlock()
+		}
+	}
+}
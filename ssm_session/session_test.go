@@ -0,0 +1,376 @@
+package ssm_session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/gorilla/websocket"
+)
+
+// fakeAgent is the agent end of a data channel.
+type fakeAgent struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// send sends an output message with the given sequence number.
+func (a *fakeAgent) send(seq int64, payloadType uint32, payload string) {
+	a.sendMessage(newMessage(outputStreamMessage, seq, payloadType, []byte(payload)))
+}
+
+func (a *fakeAgent) sendMessage(msg *clientMessage) {
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		a.t.Error(err)
+		return
+	}
+
+	err = a.conn.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		a.t.Errorf("agent send: %v", err)
+	}
+}
+
+// read reads the next message of the client, which must be of messageType.
+func (a *fakeAgent) read(messageType string) *clientMessage {
+	a.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	_, data, err := a.conn.ReadMessage()
+	if err != nil {
+		a.t.Errorf("agent read: %v", err)
+		return &clientMessage{}
+	}
+
+	msg := &clientMessage{}
+	err = msg.UnmarshalBinary(data)
+	if err != nil {
+		a.t.Errorf("agent read: %v", err)
+	}
+	if msg.MessageType != messageType {
+		a.t.Errorf("agent read a %s message, want %s", msg.MessageType, messageType)
+	}
+
+	return msg
+}
+
+// readAck reads an acknowledgement and returns the sequence number it
+// acknowledges.
+func (a *fakeAgent) readAck() int64 {
+	msg := a.read(acknowledgeMessage)
+
+	var ack acknowledgement
+	err := json.Unmarshal(msg.Payload, &ack)
+	if err != nil {
+		a.t.Errorf("agent read acknowledgement: %v", err)
+	}
+	if ack.AcknowledgedMessageType != outputStreamMessage {
+		a.t.Errorf("acknowledged a %s message", ack.AcknowledgedMessageType)
+	}
+
+	return ack.AcknowledgedMessageSequenceNumber
+}
+
+// ack acknowledges an input message.
+func (a *fakeAgent) ack(msg *clientMessage) {
+	payload, _ := json.Marshal(acknowledgement{
+		AcknowledgedMessageType:           msg.MessageType,
+		AcknowledgedMessageId:             msg.MessageID.String(),
+		AcknowledgedMessageSequenceNumber: msg.SequenceNumber,
+		IsSequentialMessage:               true,
+	})
+	a.sendMessage(newMessage(acknowledgeMessage, 0, 0, payload))
+}
+
+// closeChannel ends the session the way the agent does.
+func (a *fakeAgent) closeChannel(output string) {
+	payload, _ := json.Marshal(map[string]string{"Output": output})
+	a.sendMessage(newMessage(channelClosedMessage, 0, 0, payload))
+}
+
+// startTestSession starts a session against a fake Session Manager whose
+// agent is played by agent. It returns the session, the error of Start and
+// the informational messages of the session.
+func startTestSession(t *testing.T, agent func(a *fakeAgent)) (*Session, error, *bytes.Buffer) {
+	t.Helper()
+
+	agentDone := make(chan struct{})
+	release := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		var open map[string]string
+		err = conn.ReadJSON(&open)
+		if err != nil || open["TokenValue"] != "token" || open["ClientVersion"] != clientVersion {
+			t.Errorf("open data channel %v: %v", open, err)
+		}
+
+		agent(&fakeAgent{t: t, conn: conn})
+		close(agentDone)
+
+		// the client ends the session
+		<-release
+	})
+
+	var server *httptest.Server
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		switch target := r.Header.Get("X-Amz-Target"); target {
+		case "AmazonSSM.StartSession":
+			fmt.Fprintf(w, `{"SessionId":"session-1","StreamUrl":"ws%s/stream","TokenValue":"token"}`, strings.TrimPrefix(server.URL, "http"))
+		case "AmazonSSM.TerminateSession":
+			fmt.Fprint(w, `{"SessionId":"session-1"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"__type":"InvalidAction","message":"unexpected %s"}`, target)
+		}
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		close(release)
+		select {
+		case <-agentDone:
+		case <-time.After(5 * time.Second):
+			t.Error("agent did not finish")
+		}
+	})
+
+	messages := &bytes.Buffer{}
+	mgr := NewManagerWithClient(ssm.New(ssm.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(server.URL),
+	}))
+	mgr.Messages = messages
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := mgr.Start(ctx, "i-1", "", nil)
+	if s != nil {
+		t.Cleanup(func() { s.Close() })
+	}
+
+	return s, err, messages
+}
+
+func TestSessionHandshake(t *testing.T) {
+	tests := []struct {
+		name         string
+		actions      string
+		wantStatuses []int
+		wantType     string
+		wantErr      error
+	}{
+		{
+			name:         "session type",
+			actions:      `[{"ActionType":"SessionType","ActionParameters":{"SessionType":"Port","Properties":{}}}]`,
+			wantStatuses: []int{actionSuccess},
+			wantType:     "Port",
+		},
+		{
+			name:         "unsupported action",
+			actions:      `[{"ActionType":"SessionType","ActionParameters":{"SessionType":"Standard_Stream"}},{"ActionType":"Compression"}]`,
+			wantStatuses: []int{actionSuccess, actionUnsupported},
+			wantType:     "Standard_Stream",
+		},
+		{
+			name:         "encryption",
+			actions:      `[{"ActionType":"KMSEncryption","ActionParameters":{"KMSKeyId":"key"}},{"ActionType":"SessionType","ActionParameters":{"SessionType":"Standard_Stream"}}]`,
+			wantStatuses: []int{actionFailed, actionSuccess},
+			wantErr:      ErrEncryptionUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response handshakeResponse
+
+			s, err, messages := startTestSession(t, func(a *fakeAgent) {
+				a.send(0, payloadHandshakeRequest, `{"AgentVersion":"3.2.0.0","RequestedClientActions":`+tt.actions+`}`)
+				if seq := a.readAck(); seq != 0 {
+					t.Errorf("acknowledged %d, want 0", seq)
+				}
+
+				msg := a.read(inputStreamMessage)
+				if msg.PayloadType != payloadHandshakeResponse || msg.SequenceNumber != 0 {
+					t.Errorf("handshake response has payload type %d and sequence number %d", msg.PayloadType, msg.SequenceNumber)
+				}
+				json.Unmarshal(msg.Payload, &response)
+				a.ack(msg)
+
+				if tt.wantErr == nil {
+					a.send(1, payloadHandshakeComplete, `{"HandshakeTimeToComplete":1,"CustomerMessage":"Welcome"}`)
+					if seq := a.readAck(); seq != 1 {
+						t.Errorf("acknowledged %d, want 1", seq)
+					}
+				}
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if response.ClientVersion != clientVersion {
+				t.Errorf("ClientVersion = %q, want %q", response.ClientVersion, clientVersion)
+			}
+			var statuses []int
+			for _, action := range response.ProcessedClientActions {
+				statuses = append(statuses, action.ActionStatus)
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("action statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+
+			if err != nil {
+				return
+			}
+			if s.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", s.Type, tt.wantType)
+			}
+			if messages.String() != "Welcome\n" {
+				t.Errorf("messages = %q, want the customer message", messages)
+			}
+		})
+	}
+}
+
+func TestSessionOutputOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		// the sequence numbers sent, each carrying the letter at its index
+		// in "abc"
+		seqs []int64
+	}{
+		{name: "in order", seqs: []int64{0, 1, 2}},
+		{name: "out of order", seqs: []int64{2, 0, 1}},
+		{name: "duplicates", seqs: []int64{0, 0, 2, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err, messages := startTestSession(t, func(a *fakeAgent) {
+				for _, seq := range tt.seqs {
+					a.send(seq, payloadOutput, "abc"[seq:seq+1])
+					// every message is acknowledged, duplicates too
+					if acked := a.readAck(); acked != seq {
+						t.Errorf("acknowledged %d, want %d", acked, seq)
+					}
+				}
+				a.closeChannel("Exiting session with sessionId: session-1.")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := io.ReadAll(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != "abc" {
+				t.Errorf("output = %q, want %q", output, "abc")
+			}
+
+			err = s.Wait()
+			if err != nil {
+				t.Errorf("Wait() = %v", err)
+			}
+			if messages.String() != "Exiting session with sessionId: session-1.\n" {
+				t.Errorf("messages = %q", messages)
+			}
+		})
+	}
+}
+
+func TestSessionInput(t *testing.T) {
+	defer func(timeout time.Duration) { resendTimeout = timeout }(resendTimeout)
+	resendTimeout = 500 * time.Millisecond
+
+	input := bytes.Repeat([]byte("0123456789"), 250)
+	received := make(chan []byte, 1)
+
+	s, err, _ := startTestSession(t, func(a *fakeAgent) {
+		a.send(0, payloadOutput, "$ ")
+		a.readAck()
+
+		var got []byte
+		var lost *clientMessage
+		for seq := int64(0); seq < 3; seq++ {
+			msg := a.read(inputStreamMessage)
+			if msg.SequenceNumber != seq || msg.PayloadType != payloadOutput {
+				t.Errorf("input %d has sequence number %d and payload type %d", seq, msg.SequenceNumber, msg.PayloadType)
+			}
+			got = append(got, msg.Payload...)
+
+			// the acknowledgement of the second chunk is lost
+			if seq == 1 {
+				lost = msg
+				continue
+			}
+			a.ack(msg)
+		}
+
+		resent := a.read(inputStreamMessage)
+		if resent.SequenceNumber != 1 || !bytes.Equal(resent.Payload, lost.Payload) {
+			t.Errorf("resent input %d, want 1", resent.SequenceNumber)
+		}
+		a.ack(resent)
+
+		received <- got
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// output that is not read holds up the acknowledgements behind it
+	go io.Copy(io.Discard, s)
+
+	n, err := s.Write(input)
+	if err != nil || n != len(input) {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, input) {
+			t.Errorf("agent received %d bytes, want the %d written", len(got), len(input))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("agent did not receive the input")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		unacked := len(s.unacked)
+		s.mu.Unlock()
+
+		if unacked == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d input messages still unacknowledged", unacked)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}